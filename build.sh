@@ -14,7 +14,7 @@ build_and_zip() {
     zip_file="${zip_file}.zip"
 
     echo "开始编译 ${goos}-${arch} 版本脚本"
-    CGO_ENABLED=0 GOOS=${goos} GOARCH=${arch} go build -o ${output_binary} ${base_dir}/pkg/cmd/inspect.go
+    CGO_ENABLED=0 GOOS=${goos} GOARCH=${arch} go build -o ${output_binary} ${base_dir}/pkg/cmd
 
     if [ $? -ne 0 ]; then
         echo "编译失败，请检查错误信息。"
@@ -92,6 +92,20 @@ download_dependencies() {
     else
         echo "echarts文件已存在，跳过下载"
     fi
+
+    # PDF 报告使用的中文字体
+    local font_url="https://github.com/lxgw/LxgwWenKai/releases/download/v1.330/LXGWWenKai-Regular.ttf"
+    if [ ! -s "${target_dir}/LXGWWenKai-Regular.ttf" ]; then
+        echo "未找到中文字体文件，开始下载..."
+        wget -O "${target_dir}/LXGWWenKai-Regular.ttf" "$font_url"
+        if [ $? -eq 0 ]; then
+            echo "下载成功，文件已保存到 $target_dir"
+        else
+            echo "下载失败，请检查网络或URL"
+        fi
+    else
+        echo "中文字体文件已存在，跳过下载"
+    fi
 }
 
 build() {
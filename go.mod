@@ -3,10 +3,13 @@ module inspect
 go 1.20
 
 require (
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/go-sql-driver/mysql v1.8.0
 	github.com/lib/pq v1.10.9
 	github.com/liushuochen/gotable v0.0.0-20221119160816-1113793e7092
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/tealeg/xlsx v1.0.5
 	golang.org/x/crypto v0.11.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"inspect/pkg/common"
+	"inspect/pkg/report"
+)
+
+func diffCommand(args []string) error {
+	var formats, outputDir string
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(os.Stderr, common.T("对比两次巡检的 JSON 格式报告，输出新增/已解决异常、机器变化、用量变化等内容"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T("[使用方法]\n jms_inspect[exe] diff [-format html,md,json] 旧报告路径 新报告路径"))
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&formats, "format", "html,md,json", common.T("需要生成的对比报告格式，多个格式中间用逗号隔开(html、md、json)"))
+	addOutputFlag(fs, &outputDir)
+	addLangFlag(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New(common.T("请指定两个 JSON 格式的报告文件路径"))
+	}
+	oldSummary, err := report.LoadJsonReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newSummary, err := report.LoadJsonReport(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	dr := report.DiffReport{
+		BaseReport: report.BaseReport{ReportDir: common.NewOutputDir(outputDir)},
+		Diff:       report.DiffReports(oldSummary, newSummary),
+	}
+	for _, format := range strings.Split(formats, ",") {
+		var generateErr error
+		switch strings.TrimSpace(format) {
+		case "html":
+			generateErr = dr.GenerateHtml()
+		case "md":
+			generateErr = dr.GenerateMarkdown()
+		case "json":
+			generateErr = dr.GenerateJson()
+		default:
+			return fmt.Errorf(common.T("不支持的对比报告格式: %s"), format)
+		}
+		if generateErr != nil {
+			return fmt.Errorf(common.T("生成 %s 格式对比报告错误: %w"), format, generateErr)
+		}
+	}
+	fmt.Printf(common.T("对比报告生成完成，路径: \n%s\n"), dr.ReportDir)
+	return nil
+}
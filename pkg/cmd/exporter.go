@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"inspect/pkg/common"
+	"inspect/pkg/report"
+	"inspect/pkg/task"
+)
+
+type collectorState struct {
+	metrics     []task.Metric
+	success     bool
+	duration    float64
+	lastRunTime time.Time
+}
+
+// liveExporter 按各采集项的间隔在后台采集，/metrics 请求只读取缓存结果
+type liveExporter struct {
+	sync.RWMutex
+
+	opts       *task.Options
+	collectors []task.LiveCollector
+	states     map[string]*collectorState
+}
+
+func defaultCollectIntervals() string {
+	var items []string
+	for _, collector := range task.LiveCollectors() {
+		items = append(items, fmt.Sprintf("%s=%s", collector.Name, collector.Interval))
+	}
+	return strings.Join(items, ",")
+}
+
+// parseCollectIntervals 解析 redis=30s,disk=5m 格式的采集间隔，只启用列出的采集项
+func parseCollectIntervals(value string) ([]task.LiveCollector, error) {
+	available := make(map[string]task.LiveCollector)
+	var names []string
+	for _, collector := range task.LiveCollectors() {
+		available[collector.Name] = collector
+		names = append(names, collector.Name)
+	}
+	var collectors []task.LiveCollector
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, interval, _ := strings.Cut(item, "=")
+		collector, exist := available[strings.TrimSpace(name)]
+		if !exist {
+			return nil, fmt.Errorf(
+				common.T("不支持的采集项: %s，可选采集项: %s"), name, strings.Join(names, "、"),
+			)
+		}
+		if interval = strings.TrimSpace(interval); interval != "" {
+			duration, err := time.ParseDuration(interval)
+			if err != nil || duration < time.Second {
+				return nil, fmt.Errorf(common.T("采集项 %s 的间隔 %s 无效，最小为 1s"), name, interval)
+			}
+			collector.Interval = duration
+		}
+		collectors = append(collectors, collector)
+	}
+	return collectors, nil
+}
+
+// newLiveExporter 采集项使用配置的快照，不与巡检共用机器连接
+func newLiveExporter(opts *task.Options, collectors []task.LiveCollector) *liveExporter {
+	return &liveExporter{
+		opts: opts.LiveSnapshot(), collectors: collectors, states: make(map[string]*collectorState),
+	}
+}
+
+func (e *liveExporter) collect(collector task.LiveCollector) {
+	start := time.Now()
+	metrics, err := collector.Collect(e.opts)
+	state := &collectorState{
+		metrics: metrics, success: err == nil,
+		duration: time.Since(start).Seconds(), lastRunTime: start,
+	}
+
+	e.Lock()
+	previous := e.states[collector.Name]
+	e.states[collector.Name] = state
+	e.Unlock()
+	// 只在状态变化时输出日志，避免周期采集刷屏
+	if err != nil && (previous == nil || previous.success) {
+		logger.Warning("采集项 %s 执行失败: %s", collector.Name, err)
+	} else if err == nil && previous != nil && !previous.success {
+		logger.Info("采集项 %s 已恢复", collector.Name)
+	}
+}
+
+// Start 每个采集项启动一个协程，启动时立即采集一次
+func (e *liveExporter) Start(ctx context.Context) {
+	for _, collector := range e.collectors {
+		go func(collector task.LiveCollector) {
+			ticker := time.NewTicker(collector.Interval)
+			defer ticker.Stop()
+			for {
+				e.collect(collector)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(collector)
+	}
+}
+
+func (e *liveExporter) metrics() []task.Metric {
+	e.RLock()
+	defer e.RUnlock()
+	var metrics, states []task.Metric
+	for _, collector := range e.collectors {
+		state, exist := e.states[collector.Name]
+		if !exist {
+			continue
+		}
+		metrics = append(metrics, state.metrics...)
+		labels := map[string]string{"collector": collector.Name}
+		success := 0.0
+		if state.success {
+			success = 1
+		}
+		states = append(states,
+			task.Metric{Name: "collector_success", Labels: labels, Value: success, Unit: task.UnitBool},
+			task.Metric{Name: "collector_duration", Labels: labels, Value: state.duration, Unit: task.UnitSeconds},
+			task.Metric{
+				Name: "collector_last_run_timestamp", Labels: labels,
+				Value: float64(state.lastRunTime.Unix()), Unit: task.UnitSeconds,
+			},
+		)
+	}
+	return append(metrics, states...)
+}
+
+func (e *liveExporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(report.PrometheusText(e.metrics())))
+}
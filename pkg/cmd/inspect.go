@@ -1,102 +1,164 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 	"inspect/pkg/common"
+	"inspect/pkg/inspect"
 	"inspect/pkg/report"
 	"inspect/pkg/task"
+	"inspect/pkg/vault"
 	"os"
+	"strings"
 )
 
 const DefaultJMSConfigPath = "/opt/jumpserver/config/config.txt"
+const DefaultReportFormats = "html,json,xlsx,md,pdf"
 const version = "dev"
 
 var logger *common.Logger
 
-func main() {
-	logger = common.GetLogger()
-	opts := task.Options{Logger: logger}
-	defer opts.Clear()
+// 子命令，未匹配到子命令时执行巡检
+var subCommands = map[string]func(args []string) error{
+	"validate": validateCommand,
+	"render":   renderCommand,
+	"diff":     diffCommand,
+	"serve":    serveCommand,
+	"vault":    vaultCommand,
+}
 
-	flag.Usage = func() {
-		_, _ = fmt.Fprintf(os.Stderr, "JumpServer 巡检脚本工具, 版本: %s\n", version)
-		_, _ = fmt.Fprintf(os.Stderr, "该工具用于自动化检查系统中各个组件的状态，包括网络连接、服务运行情况等。通过此工具，您可以快速识别潜在问题，提高系统维护效率。\n")
-		_, _ = fmt.Fprintf(os.Stderr, "[使用方法]\n jms_inspect[exe] -参数选项 参数值\n")
-		flag.PrintDefaults()
+func main() {
+	if err := common.SetLanguage(common.DetectLanguage(os.Args[1:])); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if len(os.Args) > 1 {
+		if command, exist := subCommands[os.Args[1]]; exist {
+			if err := command(os.Args[2:]); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 	}
-	flag.StringVar(
-		&opts.JMSConfigPath, "jc", DefaultJMSConfigPath, "堡垒机配置文件路径",
+	runInspect()
+}
+
+// addLangFlag 语言已在 main 中提前生效，这里注册参数只为通过参数校验并展示帮助信息
+func addLangFlag(fs *flag.FlagSet) {
+	fs.String(
+		"lang", common.Language(),
+		common.Tf("输出信息及报告的语言(%s)，也可通过环境变量 %s 指定", strings.Join(common.Languages(), "、"), common.LangEnv),
+	)
+}
+
+// addOutputFlag 注册报告输出根目录参数，巡检、render、diff 共用
+func addOutputFlag(fs *flag.FlagSet, outputDir *string) {
+	fs.StringVar(
+		outputDir, "output", *outputDir,
+		common.T("报告输出根目录，每次在其下创建以时间命名的子目录，默认为当前目录下的 output"),
 	)
-	flag.StringVar(
-		&opts.MachineInfoPath, "mt", opts.MachineInfoPath,
-		"待巡检机器配置文件路径(查看脚本压缩包内 machine-demo.csv/yml 文件)",
+}
+
+// addInspectFlags 注册巡检相关参数，单次巡检与 serve 模式共用
+func addInspectFlags(fs *flag.FlagSet, cfg *inspect.Config, formatValue *string) {
+	fs.StringVar(
+		&cfg.JMSConfigPath, "jc", DefaultJMSConfigPath, common.T("堡垒机配置文件路径"),
 	)
-	flag.StringVar(
-		&opts.ExcludeTask, "et", opts.ExcludeTask,
-		"不执行的任务，多个任务中间用逗号隔开(rds、redis)",
+	fs.StringVar(
+		&cfg.MachineInfoPath, "mt", cfg.MachineInfoPath,
+		common.T("待巡检机器配置文件路径(查看脚本压缩包内 machine-demo.csv/yml 文件)"),
 	)
-	flag.BoolVar(
-		&opts.Debug, "debug", opts.Debug, "开启调试模式",
+	fs.StringVar(
+		&cfg.ExcludeTask, "et", cfg.ExcludeTask,
+		common.T("不执行的任务，多个任务中间用逗号隔开(rds、redis、tls、celery、storage)"),
 	)
-	flag.BoolVar(
-		&opts.Silent, "silent", opts.Silent, "是否静默执行，开启后将不输入非 Error 类型日志信息",
+	fs.BoolVar(
+		&cfg.Debug, "debug", cfg.Debug, common.T("开启调试模式，调试日志写入前会脱敏，但 8 位以下的密码出现在非取值位置时可能无法识别"),
 	)
-	flag.Parse()
+	fs.BoolVar(
+		&cfg.Silent, "silent", cfg.Silent, common.T("是否静默执行，开启后将不输入非 Error 类型日志信息"),
+	)
+	nonInteractiveUsage := common.T("非交互模式，不提示输入密码及确认，缺少的密码从环境变量或文件读取，适用于定时任务")
+	fs.BoolVar(&cfg.NonInteractive, "yes", cfg.NonInteractive, nonInteractiveUsage)
+	fs.BoolVar(&cfg.NonInteractive, "non-interactive", cfg.NonInteractive, nonInteractiveUsage)
+	fs.StringVar(
+		&cfg.CredentialsPath, "credentials", os.Getenv(task.CredentialsEnv),
+		common.Tf("凭据文件路径，YAML 格式，以机器名称为键配置 password、privilege_password，也可通过环境变量 %s 指定", task.CredentialsEnv),
+	)
+	fs.StringVar(
+		&cfg.VaultPasswordFile, "vault-password-file", cfg.VaultPasswordFile,
+		common.Tf("凭据库主密码文件路径，未设置环境变量 %s 时使用", vault.PasswordEnv),
+	)
+	addOutputFlag(fs, &cfg.OutputDir)
+	fs.StringVar(
+		formatValue, "format", DefaultReportFormats,
+		common.Tf("生成的报告格式，多个格式中间用逗号隔开(%s)", strings.Join(report.Formats(), "、")),
+	)
+	addLangFlag(fs)
+}
 
-	if opts.Silent {
-		opts.Logger.SetSilent()
+func runInspect() {
+	logger = common.GetLogger()
+	cfg := inspect.Config{Logger: logger}
+	var formatValue string
+
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, common.T("JumpServer 巡检脚本工具, 版本: %s\n"), version)
+		_, _ = fmt.Fprintln(os.Stderr, common.T("该工具用于自动化检查系统中各个组件的状态，包括网络连接、服务运行情况等。通过此工具，您可以快速识别潜在问题，提高系统维护效率。"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T("[使用方法]\n jms_inspect[exe] -参数选项 参数值"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T(" jms_inspect[exe] validate 报告文件路径"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T(" jms_inspect[exe] render -format html,xlsx 报告文件路径"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T(" jms_inspect[exe] diff 旧报告路径 新报告路径"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T(" jms_inspect[exe] serve -listen :8080 -schedule \"0 2 * * *\""))
+		_, _ = fmt.Fprintln(os.Stderr, common.T(" jms_inspect[exe] vault encrypt 机器配置文件路径"))
+		flag.PrintDefaults()
 	}
+	addInspectFlags(flag.CommandLine, &cfg, &formatValue)
+	flag.Parse()
 
-	logger.Debug("开始检查配置等相关信息...")
-	if err := opts.Valid(); err != nil {
-		logger.Error("参数校验错误: %v\n", err)
+	formats, err := report.ParseFormats(formatValue)
+	if err != nil {
+		logger.Fatal("参数校验错误: %v\n", err)
 	}
 
-	var resultSummary task.ResultSummary
-	var result map[string]interface{}
-	var abnormalResult []task.AbnormalMsg
-	logger.MsgOneLine(common.NoType, "")
-	logger.Info("巡检任务开始")
-	// 设置全局信息
-	resultSummary.SetGlobalInfo(&opts)
-	// 执行摘要任务
-	summaryTask := task.SummaryTask{}
-	result, _ = task.DoTask(&summaryTask, &opts)
-	resultSummary.VirtualResult = result
-	// 执行组件依赖任务
-	dbTask := task.DBTask{}
-	result, _ = task.DoTask(&dbTask, &opts)
-	resultSummary.DBResult = result
+	if cfg.Silent {
+		logger.SetSilent()
+	}
 
-	var resultList []map[string]interface{}
-	for _, m := range opts.MachineSet {
-		executor := m.GetExecutor()
-		executor.Logger = logger
-		result, abnormalResult = executor.Execute(&opts)
-		result["MachineType"] = m.Type
-		result["MachineName"] = m.Name
-		resultList = append(resultList, result)
-		for _, msg := range abnormalResult {
-			msg.NodeName = m.Name
-			resultSummary.SetAbnormalResult(msg)
-		}
+	ctx := context.Background()
+	opts, err := inspect.Prepare(ctx, cfg)
+	if errors.Is(err, task.ErrCanceled) {
+		logger.Exit(0)
+	}
+	if err != nil {
+		logger.Fatal("参数校验错误: %v\n", err)
 	}
-	resultSummary.NormalResults = resultList
+	defer opts.Clear()
 
-	hr := report.HtmlReport{Summary: &resultSummary}
-	if err := hr.Generate(); err != nil {
-		logger.Error("生成 HTML 格式报告错误: %s", err)
+	paths, err := runTasks(ctx, opts, opts.ReportDir(), formats)
+	if err != nil {
+		logger.Fatal("%s", err)
 	}
-	jr := report.JsonReport{Summary: &resultSummary}
-	if err := jr.Generate(); err != nil {
-		logger.Error("生成 Json 格式报告错误: %s", err)
+	logger.Finished(
+		"巡检完成，请将以下巡检文件发送给技术工程师: \n%s", strings.Join(paths, "\n"),
+	)
+}
+
+// runTasks 执行一次巡检并在 dir 下生成报告，opts 需已通过 inspect.Prepare 校验
+func runTasks(ctx context.Context, opts *task.Options, dir string, formats []string) ([]string, error) {
+	resultSummary, err := inspect.Execute(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
-	er := report.ExcelReport{Summary: &resultSummary}
-	if err := er.Generate(); err != nil {
-		logger.Error("生成 Excel 格式报告错误: %s", err)
+	if records, err := report.AppendHistory(dir, resultSummary); err != nil {
+		logger.Warning("记录巡检历史失败: %s", err)
+	} else if len(records) > 1 {
+		resultSummary.TrendData = report.BuildTrendData(records)
 	}
-	logger.Finished("巡检完成，请将此路径下的巡检文件发送给技术工程师: \n%s", hr.ReportDir)
+	return report.Generate(dir, formats, resultSummary)
 }
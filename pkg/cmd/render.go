@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"inspect/pkg/common"
+	"inspect/pkg/report"
+)
+
+func renderCommand(args []string) error {
+	var formatValue, outputDir string
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(os.Stderr, common.T("根据已有的 JSON 格式巡检报告重新生成其他格式报告"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T("[使用方法]\n jms_inspect[exe] render [-format html,xlsx] 报告文件路径"))
+		fs.PrintDefaults()
+	}
+	fs.StringVar(
+		&formatValue, "format", "html,xlsx",
+		common.Tf("需要生成的报告格式，多个格式中间用逗号隔开(%s)", strings.Join(report.Formats(), "、")),
+	)
+	addOutputFlag(fs, &outputDir)
+	addLangFlag(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New(common.T("请指定一个 JSON 格式的报告文件路径"))
+	}
+	formats, err := report.ParseFormats(formatValue)
+	if err != nil {
+		return err
+	}
+	summary, err := report.LoadJsonReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	paths, err := report.Generate(common.NewOutputDir(outputDir), formats, summary)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(common.T("报告生成完成，文件列表: \n%s\n"), strings.Join(paths, "\n"))
+	return nil
+}
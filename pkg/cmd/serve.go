@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"inspect/pkg/common"
+	"inspect/pkg/inspect"
+	"inspect/pkg/report"
+	"inspect/pkg/task"
+)
+
+const (
+	// 默认只监听本机，监听其他地址时必须设置令牌
+	DefaultServeListen   = "127.0.0.1:8080"
+	DefaultServeSchedule = "0 2 * * *"
+	DefaultServeKeep     = 10
+)
+
+type serveRun struct {
+	ID        string     `json:"id"`
+	Trigger   string     `json:"trigger"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	Files     []string   `json:"files,omitempty"`
+	Error     string     `json:"error,omitempty"`
+
+	dir string
+}
+
+type serveReport struct {
+	ID    string   `json:"id"`
+	Files []string `json:"files"`
+}
+
+type inspectServer struct {
+	sync.Mutex
+
+	opts       *task.Options
+	formats    []string
+	keep       int
+	token      string
+	schedule   string
+	outputRoot string
+
+	cron     *cron.Cron
+	entry    cron.EntryID
+	exporter *liveExporter
+	running  *serveRun
+	lastRun  *serveRun
+}
+
+func serveCommand(args []string) error {
+	logger = common.GetLogger()
+	cfg := inspect.Config{Logger: logger}
+
+	var formatValue, listen, schedule, token, collectValue string
+	var keep int
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(os.Stderr, common.T("长驻运行，按计划定时巡检并通过 HTTP API 触发巡检、查询状态及下载报告"))
+		_, _ = fmt.Fprintln(os.Stderr, common.T("[使用方法]\n jms_inspect[exe] serve [-listen :8080] [-schedule \"0 2 * * *\"] [-keep 10] -参数选项 参数值"))
+		fs.PrintDefaults()
+	}
+	addInspectFlags(fs, &cfg, &formatValue)
+	fs.StringVar(&listen, "listen", DefaultServeListen, common.T("HTTP 服务监听地址"))
+	fs.StringVar(
+		&schedule, "schedule", DefaultServeSchedule,
+		common.T("定时巡检计划，crontab 格式(分 时 日 月 周)，为空时仅通过 API 触发"),
+	)
+	fs.IntVar(&keep, "keep", DefaultServeKeep, common.T("保留最近多少次巡检的报告"))
+	fs.StringVar(
+		&token, "token", os.Getenv("JMS_INSPECT_TOKEN"),
+		common.T("访问 API 所需的令牌，也可通过环境变量 JMS_INSPECT_TOKEN 指定，为空时不校验且只能监听本机地址"),
+	)
+	fs.StringVar(
+		&collectValue, "collect", defaultCollectIntervals(),
+		common.T("/metrics 接口的采集项及采集间隔，为空时不采集"),
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	collectors, err := parseCollectIntervals(collectValue)
+	if err != nil {
+		return err
+	}
+	formats, err := report.ParseFormats(formatValue)
+	if err != nil {
+		return err
+	}
+	if keep < 1 {
+		return errors.New(common.T("保留的报告数量至少为 1"))
+	}
+	if token == "" && !isLoopbackListen(listen) {
+		return fmt.Errorf(common.T("监听地址 %s 不是本机地址，需通过 -token 或环境变量 JMS_INSPECT_TOKEN 设置访问令牌"), listen)
+	}
+	if cfg.Silent {
+		logger.SetSilent()
+	}
+
+	opts, err := inspect.Prepare(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer opts.Clear()
+
+	server := &inspectServer{
+		opts: opts, formats: formats, keep: keep, token: token, schedule: schedule,
+		outputRoot: filepath.Dir(common.NewOutputDir(opts.OutputDir)),
+		cron:       cron.New(),
+		exporter:   newLiveExporter(opts, collectors),
+	}
+	if schedule != "" {
+		server.entry, err = server.cron.AddFunc(schedule, func() { server.run("schedule") })
+		if err != nil {
+			return fmt.Errorf(common.T("定时巡检计划 %s 格式错误: %w"), schedule, err)
+		}
+	}
+	server.cron.Start()
+	defer server.cron.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.exporter.Start(ctx)
+
+	httpServer := &http.Server{Addr: listen, Handler: server.handler()}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- httpServer.ListenAndServe()
+	}()
+	logger.Info("巡检服务已启动，监听地址: %s，定时计划: %s", listen, common.InputOrEmpty(schedule))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err = <-errChan:
+		return err
+	case <-signals:
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// isLoopbackListen 监听地址是否仅限本机访问，未指定主机(如 :8080)时监听全部地址
+func isLoopbackListen(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// run 执行一次巡检，已有巡检在执行时直接返回 false
+func (s *inspectServer) run(trigger string) bool {
+	current, err := s.reserve(trigger)
+	if err != nil {
+		logger.Warning("创建巡检输出目录失败: %s", err)
+		return false
+	}
+	if current == nil {
+		logger.Warning("上一次巡检尚未结束，忽略本次 %s 触发", trigger)
+		return false
+	}
+	s.execute(current)
+	return true
+}
+
+// reserve 在锁内登记本次巡检并创建输出目录，已有巡检在执行时返回 nil，保证同一时间只有一次巡检
+func (s *inspectServer) reserve(trigger string) (*serveRun, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.running != nil {
+		return nil, nil
+	}
+	// 每次巡检使用新的输出目录，目录名即为巡检 ID，同一秒内多次触发时追加序号
+	dir, err := common.CreateOutputDir(s.opts.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	s.running = &serveRun{
+		ID: filepath.Base(dir), Trigger: trigger, StartTime: time.Now(), dir: dir,
+	}
+	return s.running, nil
+}
+
+// execute 执行已登记的巡检，结束后释放执行状态
+func (s *inspectServer) execute(current *serveRun) {
+	paths, err := runTasks(context.Background(), s.opts, current.dir, s.formats)
+	finished := *current
+	endTime := time.Now()
+	finished.EndTime = &endTime
+	for _, path := range paths {
+		finished.Files = append(finished.Files, filepath.Base(path))
+	}
+	if err != nil {
+		finished.Error = err.Error()
+		logger.Warning("巡检失败: %s", err)
+	} else {
+		logger.Info("巡检完成，报告目录: %s", current.dir)
+	}
+	if err = s.prune(); err != nil {
+		logger.Warning("清理历史报告失败: %s", err)
+	}
+
+	s.Lock()
+	s.running, s.lastRun = nil, &finished
+	s.Unlock()
+}
+
+// reports 按时间倒序列出输出目录下包含报告文件的巡检目录
+func (s *inspectServer) reports() ([]serveReport, error) {
+	entries, err := os.ReadDir(s.outputRoot)
+	// 尚未生成过报告时输出目录还不存在
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reports []serveReport
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.outputRoot, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		r := serveReport{ID: entry.Name()}
+		for _, file := range files {
+			if !file.IsDir() && file.Name() != "inspect.log" {
+				r.Files = append(r.Files, file.Name())
+			}
+		}
+		if len(r.Files) > 0 {
+			reports = append(reports, r)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return runIDLess(reports[j].ID, reports[i].ID)
+	})
+	return reports, nil
+}
+
+// runIDLess 巡检 ID 为时间加可选的 -序号，先按时间再按序号比较
+func runIDLess(a, b string) bool {
+	split := func(id string) (string, int) {
+		base, seqText, found := strings.Cut(id, "-")
+		if !found {
+			return base, 1
+		}
+		seq, _ := strconv.Atoi(seqText)
+		return base, seq
+	}
+	baseA, seqA := split(a)
+	baseB, seqB := split(b)
+	if baseA != baseB {
+		return baseA < baseB
+	}
+	return seqA < seqB
+}
+
+func (s *inspectServer) prune() error {
+	reports, err := s.reports()
+	if err != nil {
+		return err
+	}
+	for i := s.keep; i < len(reports); i++ {
+		if err = os.RemoveAll(filepath.Join(s.outputRoot, reports[i].ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *inspectServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.auth(s.handleLatest))
+	mux.HandleFunc("/api/status", s.auth(s.handleStatus))
+	mux.HandleFunc("/api/runs", s.auth(s.handleRuns))
+	mux.HandleFunc("/api/reports", s.auth(s.handleReports))
+	mux.HandleFunc("/api/reports/", s.auth(s.handleDownload))
+	if len(s.exporter.collectors) > 0 {
+		mux.HandleFunc("/metrics", s.auth(s.exporter.ServeHTTP))
+	}
+	return mux
+}
+
+func (s *inspectServer) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := []byte("Bearer " + s.token)
+		if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": common.T("令牌无效")})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(data)
+}
+
+func (s *inspectServer) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	s.Lock()
+	status := map[string]interface{}{
+		"schedule": s.schedule,
+		"running":  s.running,
+		"last_run": s.lastRun,
+	}
+	if s.entry != 0 {
+		status["next_run"] = s.cron.Entry(s.entry).Next
+	}
+	s.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleRuns POST 触发一次巡检，巡检在后台执行
+func (s *inspectServer) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": common.T("仅支持 POST 请求")})
+		return
+	}
+	current, err := s.reserve("api")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": common.T("已有巡检正在执行")})
+		return
+	}
+	go s.execute(current)
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": common.T("巡检已开始")})
+}
+
+func (s *inspectServer) handleReports(w http.ResponseWriter, _ *http.Request) {
+	reports, err := s.reports()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// handleDownload 下载 /api/reports/{id}/{file}，仅允许访问已列出的报告文件
+func (s *inspectServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/reports/"), "/")
+	if len(parts) != 2 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": common.T("报告不存在")})
+		return
+	}
+	reports, err := s.reports()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	for _, rep := range reports {
+		if rep.ID != parts[0] {
+			continue
+		}
+		for _, file := range rep.Files {
+			if file == parts[1] {
+				w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(file)))
+				http.ServeFile(w, r, filepath.Join(s.outputRoot, rep.ID, file))
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": common.T("报告不存在")})
+}
+
+// handleLatest 首页直接展示最近一次的 HTML 报告
+func (s *inspectServer) handleLatest(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	reports, err := s.reports()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, rep := range reports {
+		for _, file := range rep.Files {
+			if strings.HasSuffix(file, ".html") {
+				http.ServeFile(w, r, filepath.Join(s.outputRoot, rep.ID, file))
+				return
+			}
+		}
+	}
+	http.Error(w, common.T("暂无 HTML 格式巡检报告"), http.StatusNotFound)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"inspect/pkg/task"
+)
+
+func TestIsLoopbackListen(t *testing.T) {
+	cases := []struct {
+		listen string
+		want   bool
+	}{
+		{"127.0.0.1:8080", true},
+		{"localhost:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"10.0.0.1:8080", false},
+		{"8080", false},
+	}
+	for _, c := range cases {
+		if got := isLoopbackListen(c.listen); got != c.want {
+			t.Errorf("isLoopbackListen(%q) = %v, want %v", c.listen, got, c.want)
+		}
+	}
+}
+
+func TestRunIDLess(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"20261019031616", "20261019031617", true},
+		{"20261019031616", "20261019031616-2", true},
+		{"20261019031616-2", "20261019031616-10", true},
+		{"20261019031616-10", "20261019031617", true},
+		{"20261019031617", "20261019031616-2", false},
+	}
+	for _, c := range cases {
+		if got := runIDLess(c.a, c.b); got != c.want {
+			t.Errorf("runIDLess(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+// 同一秒内连续触发的巡检需使用不同的 ID 及输出目录
+func TestReserveUniqueRun(t *testing.T) {
+	s := &inspectServer{opts: &task.Options{OutputDir: t.TempDir()}}
+	first, err := s.reserve("schedule")
+	if err != nil || first == nil {
+		t.Fatalf("reserve = %v, %v", first, err)
+	}
+	if busy, err := s.reserve("api"); busy != nil || err != nil {
+		t.Fatalf("巡检执行中时 reserve = %v, %v, want nil", busy, err)
+	}
+	s.running = nil
+	second, err := s.reserve("api")
+	if err != nil || second == nil {
+		t.Fatalf("reserve = %v, %v", second, err)
+	}
+	if first.ID == second.ID || first.dir == second.dir {
+		t.Fatalf("两次巡检使用了相同的目录 %s", first.dir)
+	}
+	for _, dir := range []string{first.dir, second.dir} {
+		if _, err = os.Stat(dir); err != nil {
+			t.Errorf("目录 %s 未创建: %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"inspect/pkg/common"
+	"inspect/pkg/report"
+)
+
+func validateCommand(args []string) error {
+	var printSchema bool
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, common.T("校验 JSON 格式巡检报告是否符合报告结构定义(版本 %s)\n"), report.JsonSchemaVersion)
+		_, _ = fmt.Fprintln(os.Stderr, common.T("[使用方法]\n jms_inspect[exe] validate [-schema] 报告文件路径..."))
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&printSchema, "schema", false, common.T("输出内置的 JSON Schema 文档"))
+	addLangFlag(fs)
+	_ = fs.Parse(args)
+
+	if printSchema {
+		_, err := os.Stdout.Write(report.JsonSchema)
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New(common.T("请指定需要校验的报告文件路径"))
+	}
+	var failed int
+	for _, filepath := range fs.Args() {
+		if err := report.ValidateJsonReport(filepath); err != nil {
+			failed += 1
+			fmt.Printf(common.T("[失败] %s\n%v\n"), filepath, err)
+		} else {
+			fmt.Printf(common.T("[通过] %s\n"), filepath)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf(common.T("共 %d 个报告文件校验未通过"), failed)
+	}
+	return nil
+}
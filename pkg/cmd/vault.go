@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"inspect/pkg/common"
+	"inspect/pkg/vault"
+)
+
+// readVaultPassword 未通过环境变量或文件提供主密码时从终端读取，加密时需要输入两次
+func readVaultPassword(passwordFile string, confirm bool) (string, error) {
+	password, err := vault.PasswordFromEnvOrFile(passwordFile)
+	if err != nil || password != "" {
+		return password, err
+	}
+	_, _ = fmt.Fprint(os.Stderr, common.T("请输入凭据库主密码："))
+	input, err := terminal.ReadPassword(int(syscall.Stdin))
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		_, _ = fmt.Fprint(os.Stderr, common.T("请再次输入凭据库主密码："))
+		again, err := terminal.ReadPassword(int(syscall.Stdin))
+		_, _ = fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(input) != string(again) {
+			return "", errors.New(common.T("两次输入的主密码不一致"))
+		}
+	}
+	if len(input) == 0 {
+		return "", errors.New(common.T("主密码不能为空"))
+	}
+	return string(input), nil
+}
+
+func vaultCommand(args []string) error {
+	var passwordFile string
+	fs := flag.NewFlagSet("vault", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, common.T("加解密机器配置文件及凭据文件中的密码字段(%s)\n"), "password、ssh_key_passphrase、privilege_password")
+		_, _ = fmt.Fprintln(os.Stderr, common.T("[使用方法]\n jms_inspect[exe] vault encrypt 配置文件路径..."))
+		_, _ = fmt.Fprintln(os.Stderr, common.T(" jms_inspect[exe] vault decrypt 配置文件路径..."))
+		_, _ = fmt.Fprintln(os.Stderr, common.T(" jms_inspect[exe] vault encrypt-string 明文"))
+		fs.PrintDefaults()
+	}
+	fs.StringVar(
+		&passwordFile, "vault-password-file", "",
+		common.Tf("凭据库主密码文件路径，未设置环境变量 %s 时使用", vault.PasswordEnv),
+	)
+	addLangFlag(fs)
+	// 参数可在操作名称前后
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New(common.T("请指定操作: encrypt、decrypt 或 encrypt-string"))
+	}
+	action := fs.Arg(0)
+	_ = fs.Parse(fs.Args()[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New(common.T("请指定需要处理的文件路径或明文"))
+	}
+
+	switch action {
+	case "encrypt", "decrypt":
+		password, err := readVaultPassword(passwordFile, action == "encrypt")
+		if err != nil {
+			return err
+		}
+		transform := vault.EncryptFile
+		if action == "decrypt" {
+			transform = vault.DecryptFile
+		}
+		for _, path := range fs.Args() {
+			count, err := transform(path, password)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Printf(common.T("%s: 已处理 %d 个密码字段\n"), path, count)
+		}
+	case "encrypt-string":
+		password, err := readVaultPassword(passwordFile, true)
+		if err != nil {
+			return err
+		}
+		for _, value := range fs.Args() {
+			encrypted, err := vault.Encrypt(value, password)
+			if err != nil {
+				return err
+			}
+			fmt.Println(encrypted)
+		}
+	default:
+		fs.Usage()
+		return fmt.Errorf(common.T("不支持的操作: %s"), action)
+	}
+	return nil
+}
@@ -7,5 +7,3 @@ var FinishedCallbacks []Callback
 func AddCallback(cb Callback) {
 	FinishedCallbacks = append(FinishedCallbacks, cb)
 }
-
-var OutputDir = GetOutputDir()
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	LangZh = "zh"
+	LangEn = "en"
+
+	// LangEnv 未指定 -lang 参数时读取的环境变量
+	LangEnv = "JMS_INSPECT_LANG"
+)
+
+// 翻译目录以中文原文为键，中文无需目录
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+var (
+	language = LangZh
+	catalog  map[string]string
+)
+
+func Languages() []string {
+	return []string{LangZh, LangEn}
+}
+
+func Language() string {
+	return language
+}
+
+func SetLanguage(lang string) error {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" || lang == LangZh {
+		language, catalog = LangZh, nil
+		return nil
+	}
+	data, err := localeFS.ReadFile(fmt.Sprintf("locales/%s.json", lang))
+	if err != nil {
+		return fmt.Errorf("不支持的语言: %s，可选语言: %s", lang, strings.Join(Languages(), "、"))
+	}
+	var messages map[string]string
+	if err = json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("语言文件 %s 格式错误: %v", lang, err)
+	}
+	language, catalog = lang, messages
+	return nil
+}
+
+// DetectLanguage 在解析命令行参数之前确定语言，保证参数帮助信息也能被翻译
+func DetectLanguage(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if strings.HasPrefix(name, "lang=") {
+			return strings.TrimPrefix(name, "lang=")
+		}
+		if name == "lang" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv(LangEnv)
+}
+
+// T 返回当前语言下的文本，目录中不存在时原样返回
+func T(message string) string {
+	if translated, ok := catalog[message]; ok && translated != "" {
+		return translated
+	}
+	return message
+}
+
+func Tf(format string, a ...any) string {
+	return fmt.Sprintf(T(format), a...)
+}
@@ -19,6 +19,7 @@ const (
 	Period
 	StopMsg
 	NoType
+	Fatal
 )
 
 const (
@@ -39,6 +40,28 @@ type LogMsg struct {
 	IsFather bool
 }
 
+// Printer 巡检过程中的日志输出，作为库使用时可替换为自定义实现
+type Printer interface {
+	Debug(format string, a ...any)
+	Info(format string, a ...any)
+	Warning(format string, a ...any)
+	Error(format string, a ...any)
+	MsgOneLine(mType uint, format string, a ...any)
+	StartTip(format string, a ...any)
+	StopTip(format string, a ...any)
+}
+
+// NopPrinter 丢弃全部日志
+type NopPrinter struct{}
+
+func (NopPrinter) Debug(string, ...any)            {}
+func (NopPrinter) Info(string, ...any)             {}
+func (NopPrinter) Warning(string, ...any)          {}
+func (NopPrinter) Error(string, ...any)            {}
+func (NopPrinter) MsgOneLine(uint, string, ...any) {}
+func (NopPrinter) StartTip(string, ...any)         {}
+func (NopPrinter) StopTip(string, ...any)          {}
+
 type Logger struct {
 	spinnerFlag bool
 	silent      bool
@@ -67,7 +90,8 @@ func (l *Logger) logPrintForever() {
 				l.msgCache = []string{}
 				fmt.Print(msg.Content)
 			}
-			if msg.Type == Error {
+			// 仅 Fatal 会退出进程，作为库使用时 Error 只输出日志
+			if msg.Type == Fatal {
 				l.Exit(1)
 			}
 		}
@@ -97,22 +121,23 @@ func (l *Logger) format(mType uint, newLine bool, format string, a ...any) *LogM
 	var colorPre, colorSuf string
 	switch mType {
 	case Debug:
-		prefix = "提示"
+		prefix = T("提示")
 	case Info:
-		prefix = "信息"
+		prefix = T("信息")
 	case Warning:
-		prefix, colorPre, colorSuf = "警告", Yellow, Reset
-	case Error:
-		prefix, colorPre, colorSuf = "错误", Red, Reset
+		prefix, colorPre, colorSuf = T("警告"), Yellow, Reset
+	case Error, Fatal:
+		prefix, colorPre, colorSuf = T("错误"), Red, Reset
 	case Success:
-		prefix, colorPre, colorSuf = "成功", Green, Reset
+		prefix, colorPre, colorSuf = T("成功"), Green, Reset
 	default:
 		prefix, colorPre, colorSuf = "", "", ""
 	}
+	// 日志格式字符串即为翻译目录的键
 	if prefix != "" {
-		content = fmt.Sprintf("[%s]:> %s", prefix, fmt.Sprintf(format, a...))
+		content = fmt.Sprintf("[%s]:> %s", prefix, Tf(format, a...))
 	} else {
-		content = fmt.Sprintf(format, a...)
+		content = Tf(format, a...)
 	}
 	content = fmt.Sprintf("%s%s%s", colorPre, content, colorSuf)
 	if newLine {
@@ -122,7 +147,7 @@ func (l *Logger) format(mType uint, newLine bool, format string, a ...any) *LogM
 }
 
 func (l *Logger) PushMsg(logMsg *LogMsg) {
-	if l.silent && logMsg.Type != Error {
+	if l.silent && logMsg.Type != Error && logMsg.Type != Fatal {
 		return
 	}
 	if l.msgChan != nil {
@@ -133,14 +158,14 @@ func (l *Logger) PushMsg(logMsg *LogMsg) {
 func (l *Logger) MsgOneLine(mType uint, format string, a ...any) {
 	width, _ := GetTerminalWidth()
 	l.PushMsg(l.format(mType, false, "\r%s", strings.Repeat(" ", width)))
-	logMsg := l.format(mType, false, fmt.Sprintf(format, a...))
+	logMsg := l.format(mType, false, Tf(format, a...))
 	logMsg.Content = "\r" + logMsg.Content
 	l.PushMsg(logMsg)
 }
 
 func (l *Logger) StartTip(format string, a ...any) {
 	l.spinnerFlag = true
-	go l.pushSpinnerMsg(fmt.Sprintf(format, a...))
+	go l.pushSpinnerMsg(Tf(format, a...))
 }
 
 func (l *Logger) StopTip(format string, a ...any) {
@@ -148,7 +173,7 @@ func (l *Logger) StopTip(format string, a ...any) {
 	l.stopChan <- false
 	width, _ := GetTerminalWidth()
 	l.PushMsg(l.format(StopMsg, false, "\r%s", strings.Repeat(" ", width)))
-	l.PushMsg(l.format(StopMsg, false, "\r%s\n", fmt.Sprintf(format, a...)))
+	l.PushMsg(l.format(StopMsg, false, "\r%s\n", Tf(format, a...)))
 }
 
 func (l *Logger) Debug(format string, a ...any) {
@@ -167,8 +192,14 @@ func (l *Logger) Error(format string, a ...any) {
 	l.PushMsg(l.format(Error, true, format, a...))
 }
 
+// Fatal 输出错误信息后退出进程，仅供命令行使用
+func (l *Logger) Fatal(format string, a ...any) {
+	l.PushMsg(l.format(Fatal, true, format, a...))
+	select {}
+}
+
 func (l *Logger) Finished(format string, a ...any) {
-	fmt.Println(fmt.Sprintf(format, a...))
+	fmt.Println(Tf(format, a...))
 	l.Exit(0)
 }
 
@@ -201,8 +232,13 @@ type DebugLogger struct {
 	content string
 }
 
+// Write 写入前脱敏，避免数据库参数、配置等内容中的密码写入日志文件
 func (l *DebugLogger) Write(obj interface{}) error {
-	jsonData, err := json.MarshalIndent(obj, "", "  ")
+	redacted, err := RedactObject(obj)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.MarshalIndent(redacted, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -217,11 +253,17 @@ func (l *DebugLogger) Close() {
 	if l.file != nil {
 		_, _ = l.file.WriteString(l.content)
 		_ = l.file.Close()
+		l.file = nil
 	}
 }
 
-func NewDebugLogger() *DebugLogger {
-	filePath := path.Join(OutputDir, "inspect.log")
-	file, _ := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	return &DebugLogger{file: file, content: ""}
+func NewDebugLogger(dir string) (*DebugLogger, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path.Join(dir, "inspect.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &DebugLogger{file: file, content: ""}, nil
 }
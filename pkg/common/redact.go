@@ -0,0 +1,176 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 调试日志及报告写入前统一脱敏: 敏感字段名对应的值、文本中 key=value 形式的密码、URL 中的密码
+// 以及登记过的密码明文均替换为掩码，密码明文仅在前后不是字母或数字时替换
+
+const (
+	RedactedMask = "******"
+	// 较短的密码(如 root、admin)与用户名、路径等正常内容重合的概率高，不做全文替换，
+	// 只替换 = 或 : 之后、引号内及 -p 参数中的值
+	minSecretLength = 8
+	// 短于此长度的密码只能通过敏感字段名及 key=value 形式脱敏
+	minShortSecretLength = 4
+)
+
+var secretKeyPattern = regexp.MustCompile(
+	`(?i)(password|passwd|pwd|passphrase|secret|secret_key|token|access_key|private_key|credentials?)$`,
+)
+
+var secretTextPatterns = []struct {
+	pattern *regexp.Regexp
+	repl    string
+}{
+	{
+		regexp.MustCompile(`(?i)((?:password|passwd|pwd|passphrase|secret|secret_key|token|access_key|private_key)["']?\s*[=:]\s*["']?)([^\s"',;&]+)`),
+		"${1}" + RedactedMask,
+	},
+	{regexp.MustCompile(`(://[^:/\s@]+:)([^@\s/]+)(@)`), "${1}" + RedactedMask + "${3}"},
+}
+
+var registeredSecrets struct {
+	sync.RWMutex
+	// 较短的密码对应只匹配取值位置的正则，足够长的密码为 nil，按全文替换
+	values map[string]*regexp.Regexp
+}
+
+// shortSecretPattern 匹配 =、: 之后，引号内以及 -p 参数中的密码
+func shortSecretPattern(secret string) *regexp.Regexp {
+	return regexp.MustCompile(
+		`([=:]\s*["']?|\s-p["']?|["'])` + regexp.QuoteMeta(secret) + `(["'\s,;&)]|$)`,
+	)
+}
+
+// RegisterSecret 登记密码明文，之后出现在日志及报告中的相同内容都会被替换
+func RegisterSecret(values ...string) {
+	registeredSecrets.Lock()
+	defer registeredSecrets.Unlock()
+	if registeredSecrets.values == nil {
+		registeredSecrets.values = make(map[string]*regexp.Regexp)
+	}
+	for _, value := range values {
+		if _, exist := registeredSecrets.values[value]; exist {
+			continue
+		}
+		if len(value) >= minSecretLength {
+			registeredSecrets.values[value] = nil
+		} else if len(value) >= minShortSecretLength {
+			registeredSecrets.values[value] = shortSecretPattern(value)
+		}
+	}
+}
+
+// RegisterConfigSecrets 登记配置中敏感字段的值
+func RegisterConfigSecrets(config map[string]string) {
+	for key, value := range config {
+		if IsSecretKey(key) {
+			RegisterSecret(value)
+		}
+	}
+}
+
+func IsSecretKey(key string) bool {
+	return secretKeyPattern.MatchString(strings.TrimSpace(key))
+}
+
+// RedactString 先替换登记的密码明文，避免密码中含有 @ 等字符时按 URL 规则只替换了一部分
+func RedactString(value string) string {
+	registeredSecrets.RLock()
+	for secret, pattern := range registeredSecrets.values {
+		if pattern != nil {
+			value = pattern.ReplaceAllString(value, "${1}"+RedactedMask+"${2}")
+		} else {
+			value = replaceSecret(value, secret)
+		}
+	}
+	registeredSecrets.RUnlock()
+	for _, p := range secretTextPatterns {
+		value = p.pattern.ReplaceAllString(value, p.repl)
+	}
+	return value
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// replaceSecret 替换 value 中独立出现的 secret，前后紧邻字母或数字时视为其他内容的一部分
+func replaceSecret(value, secret string) string {
+	var b strings.Builder
+	for {
+		index := strings.Index(value, secret)
+		if index < 0 {
+			b.WriteString(value)
+			return b.String()
+		}
+		end := index + len(secret)
+		before, _ := utf8.DecodeLastRuneInString(value[:index])
+		after, _ := utf8.DecodeRuneInString(value[end:])
+		b.WriteString(value[:index])
+		if (index > 0 && isWordRune(before)) || (end < len(value) && isWordRune(after)) {
+			b.WriteString(secret)
+		} else {
+			b.WriteString(RedactedMask)
+		}
+		value = value[end:]
+	}
+}
+
+func redactNode(key string, node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactNode(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactNode(key, child)
+		}
+		return v
+	case string:
+		if v != "" && IsSecretKey(key) {
+			return RedactedMask
+		}
+		return RedactString(v)
+	default:
+		return v
+	}
+}
+
+// RedactObject 按 JSON 结构脱敏，只处理字符串值，返回通用的 map/slice 结构
+func RedactObject(obj interface{}) (interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var node interface{}
+	if err = decoder.Decode(&node); err != nil {
+		return nil, err
+	}
+	return redactNode("", node), nil
+}
+
+// RedactInto 将 src 脱敏后的内容写入 dst，src 不会被修改
+func RedactInto(src, dst interface{}) error {
+	node, err := RedactObject(src)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
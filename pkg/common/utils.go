@@ -3,16 +3,18 @@ package common
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
 func ConfigFileToMap(filepath string) (map[string]string, error) {
-	configMap := make(map[string]string)
 	file, err := os.Open(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("打开文件[%s]失败，错误: %v", filepath, err)
@@ -20,8 +22,13 @@ func ConfigFileToMap(filepath string) (map[string]string, error) {
 	defer func(file *os.File) {
 		_ = file.Close()
 	}(file)
+	return ParseConfig(file), nil
+}
 
-	scanner := bufio.NewScanner(file)
+// ParseConfig 解析 config.txt 格式(KEY=VALUE，# 开头为注释)的内容
+func ParseConfig(r io.Reader) map[string]string {
+	configMap := make(map[string]string)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		value := strings.TrimSpace(scanner.Text())
 		if strings.HasPrefix(value, "#") {
@@ -33,7 +40,7 @@ func ConfigFileToMap(filepath string) (map[string]string, error) {
 		}
 		configMap[items[0]] = items[1]
 	}
-	return configMap, nil
+	return configMap
 }
 
 func BoolDisplay(value interface{}) string {
@@ -58,17 +65,17 @@ func SecondDisplay(second int) string {
 	duration := time.Duration(second) * time.Second
 	hours := duration / time.Hour
 	if hours != 0 {
-		display += fmt.Sprintf("%d 小时", hours)
+		display += Tf("%d 小时", hours)
 	}
 	duration = duration % time.Hour
 	minutes := duration / time.Minute
 	if minutes != 0 {
-		display += fmt.Sprintf("%d 分钟", minutes)
+		display += Tf("%d 分钟", minutes)
 	}
 	duration = duration % time.Minute
 	seconds := duration / time.Second
 	if seconds != 0 {
-		display += fmt.Sprintf("%d 秒", minutes)
+		display += Tf("%d 秒", seconds)
 	}
 	return display
 }
@@ -80,7 +87,7 @@ func CurrentDatetime(needType string) string {
 	case "file":
 		currentDisplay = current.Format("20060102_150405")
 	case "dir":
-		currentDisplay = current.Format("2006010215044")
+		currentDisplay = current.Format("20060102150405")
 	default:
 		currentDisplay = current.Format("2006-01-02 15:04:05")
 	}
@@ -109,15 +116,32 @@ func SpaceDisplay(sizeKB int64) string {
 	}
 }
 
-func GetOutputDir() string {
-	current, _ := os.Getwd()
-	outputDir := path.Join(current, "output", CurrentDatetime("dir"))
-	if file, err := os.Stat(outputDir); err != nil || !file.IsDir() {
-		if err = os.MkdirAll(outputDir, 0700); err != nil {
-			return ""
+// NewOutputDir 返回本次输出的目录 root/时间，root 为空时使用当前目录下的 output，目录在写入文件时才创建
+func NewOutputDir(root string) string {
+	if root == "" {
+		current, _ := os.Getwd()
+		root = path.Join(current, "output")
+	}
+	return path.Join(root, CurrentDatetime("dir"))
+}
+
+// CreateOutputDir 创建本次输出的目录，同一秒内已存在同名目录时依次追加 -2、-3 等序号，保证每次输出互不覆盖
+func CreateOutputDir(root string) (string, error) {
+	base := NewOutputDir(root)
+	if err := os.MkdirAll(path.Dir(base), 0700); err != nil {
+		return "", err
+	}
+	dir := base
+	for seq := 2; ; seq++ {
+		err := os.Mkdir(dir, 0700)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
 		}
+		dir = fmt.Sprintf("%s-%d", base, seq)
 	}
-	return outputDir
 }
 
 func GetTerminalWidth() (int, error) {
@@ -145,3 +169,66 @@ func InputOrEmpty(input string) string {
 		return input
 	}
 }
+
+var spaceRe = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([KMGTPE]?)(?:IB|I|B|BYTES)?$`)
+
+// ParseSpace 将 df/free/du/Redis 等命令输出的可读空间大小解析为字节数
+// 支持 512、512K、7.6Gi、20G、50.0GB、1.02M、12 MB、8192 bytes 等格式，单位均按 1024 进制计算
+func ParseSpace(display string) (int64, bool) {
+	match := spaceRe.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(display)))
+	if len(match) != 3 {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	units := "KMGTPE"
+	if idx := strings.Index(units, match[2]); match[2] != "" && idx >= 0 {
+		value *= math.Pow(1024, float64(idx+1))
+	}
+	return int64(value), true
+}
+
+// ParsePercent 解析 45%、45.5% 等百分比格式
+func ParsePercent(display string) (float64, bool) {
+	value := strings.TrimSuffix(strings.TrimSpace(display), "%")
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return result, true
+}
+
+// ParseCount 解析纯数字格式，同时兼容 "12 (2024-01-01)" 这种带日期的展示
+func ParseCount(display string) (float64, bool) {
+	fields := strings.Fields(display)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	result, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	return result, true
+}
+
+var clockDurationRe = regexp.MustCompile(`^(?:(\d+) days?)? ?(?:(\d+):(\d{2}):(\d{2})(?:\.\d+)?)?$`)
+
+// ParseClockDuration 解析数据库返回的时间差，如 MySQL 的 12:30:05、PostgreSQL 的 1 day 02:00:00 及整天的 3 days，返回秒数
+func ParseClockDuration(display string) (int64, bool) {
+	display = strings.TrimSpace(display)
+	match := clockDurationRe.FindStringSubmatch(display)
+	if display == "" || match == nil {
+		return 0, false
+	}
+	var seconds int64
+	for i, unit := range []int64{86400, 3600, 60, 1} {
+		if match[i+1] == "" {
+			continue
+		}
+		value, _ := strconv.ParseInt(match[i+1], 10, 64)
+		seconds += value * unit
+	}
+	return seconds, true
+}
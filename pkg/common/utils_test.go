@@ -0,0 +1,96 @@
+package common
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSpace(t *testing.T) {
+	cases := []struct {
+		input string
+		want  int64
+		ok    bool
+	}{
+		// df -h
+		{"50G", 50 << 30, true},
+		{"20K", 20 << 10, true},
+		{"1.5T", 3 << 39, true},
+		{"0", 0, true},
+		// free -h
+		{"7.6Gi", 8160437862, true},
+		{"512Mi", 512 << 20, true},
+		{"0B", 0, true},
+		// du -sh / 报告中的展示值
+		{"1.2GB", 1288490188, true},
+		{"12 MB", 12 << 20, true},
+		{" 22.5M ", 23592960, true},
+		{"8192 bytes", 8192, true},
+		// Redis used_memory_human
+		{"1.02M", 1069547, true},
+		{"864.00K", 864 << 10, true},
+		{"2.00P", 2 << 50, true},
+		// 无法解析
+		{"", 0, false},
+		{"-", 0, false},
+		{"12X", 0, false},
+		{"G", 0, false},
+		{"1,024K", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseSpace(c.input)
+		if got != c.want || ok != c.ok {
+			t.Errorf("ParseSpace(%q) = %d, %v, want %d, %v", c.input, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestParseClockDuration(t *testing.T) {
+	cases := []struct {
+		input string
+		want  int64
+		ok    bool
+	}{
+		// MySQL timediff
+		{"12:30:05", 12*3600 + 30*60 + 5, true},
+		{"00:00:00", 0, true},
+		{"838:59:59", 838*3600 + 59*60 + 59, true},
+		{"01:02:03.000000", 3723, true},
+		// PostgreSQL interval
+		{"02:00:00", 7200, true},
+		{"1 day 02:00:00", 86400 + 7200, true},
+		{"3 days 00:00:01.5", 3*86400 + 1, true},
+		{"1 day", 86400, true},
+		{"3 days", 3 * 86400, true},
+		// 无法解析
+		{"", 0, false},
+		{"  ", 0, false},
+		{"-", 0, false},
+		{"12:30", 0, false},
+		{"-00:01:00", 0, false},
+		{"1 mon 02:00:00", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseClockDuration(c.input)
+		if got != c.want || ok != c.ok {
+			t.Errorf("ParseClockDuration(%q) = %d, %v, want %d, %v", c.input, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestCreateOutputDir(t *testing.T) {
+	root := t.TempDir()
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		dir, err := CreateOutputDir(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[dir] {
+			t.Fatalf("CreateOutputDir 返回了重复的目录 %s", dir)
+		}
+		seen[dir] = true
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Fatalf("目录 %s 未创建: %v", dir, err)
+		}
+	}
+}
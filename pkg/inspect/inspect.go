@@ -0,0 +1,175 @@
+// Package inspect 以库的形式执行巡检，过程中不会退出进程，错误均通过返回值返回
+package inspect
+
+import (
+	"context"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+type Config struct {
+	JMSConfigPath   string
+	MachineInfoPath string
+	// 不执行的任务，多个任务中间用逗号隔开(rds、redis、tls、celery、storage)
+	ExcludeTask string
+	Debug       bool
+	// Silent 为 true 时跳过执行前的确认
+	Silent bool
+	// 为 true 时不提示输入密码及确认，缺少的密码从环境变量、password_env/password_file 或凭据文件读取
+	NonInteractive  bool
+	CredentialsPath string
+	// 机器配置中有加密的密码时，未设置环境变量 JMS_INSPECT_VAULT_PASSWORD 则从此文件读取主密码
+	VaultPasswordFile string
+	// 报告及调试日志的输出根目录，每次巡检在其下创建以时间命名的子目录，为空时使用当前目录下的 output
+	OutputDir string
+
+	// 为空时不输出日志
+	Logger common.Printer
+	// 机器未配置密码时调用，为空时从终端读取
+	PasswordPrompt func(prompt string) (string, error)
+	// 机器检查完成后确认是否继续，为空时从终端读取，返回 false 时 Run 返回 task.ErrCanceled
+	ConfirmPrompt func(prompt string) (bool, error)
+}
+
+func (c *Config) options() *task.Options {
+	logger := c.Logger
+	if logger == nil {
+		logger = common.NopPrinter{}
+	}
+	return &task.Options{
+		Logger:            logger,
+		PasswordPrompt:    c.PasswordPrompt,
+		ConfirmPrompt:     c.ConfirmPrompt,
+		Debug:             c.Debug,
+		Silent:            c.Silent,
+		JMSConfigPath:     c.JMSConfigPath,
+		MachineInfoPath:   c.MachineInfoPath,
+		ExcludeTask:       c.ExcludeTask,
+		NonInteractive:    c.NonInteractive,
+		CredentialsPath:   c.CredentialsPath,
+		VaultPasswordFile: c.VaultPasswordFile,
+		OutputDir:         c.OutputDir,
+	}
+}
+
+// Prepare 校验配置并检查机器及数据库连接，返回的 Options 可多次传给 Execute，用完后需调用 Clear
+func Prepare(ctx context.Context, cfg Config) (*task.Options, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	opts := cfg.options()
+	opts.Logger.Debug("开始检查配置等相关信息...")
+	if err := opts.Valid(); err != nil {
+		opts.Clear()
+		return nil, err
+	}
+	return opts, nil
+}
+
+// Execute 执行一次巡检，ctx 取消后不再执行后续任务
+func Execute(ctx context.Context, opts *task.Options) (*task.ResultSummary, error) {
+	var resultSummary task.ResultSummary
+	logger := opts.Logger
+	logger.MsgOneLine(common.NoType, "")
+	logger.Info("巡检任务开始")
+	// 设置全局信息
+	resultSummary.SetGlobalInfo(opts)
+	// 执行摘要任务
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	summaryTask := task.SummaryTask{}
+	result, _, record := task.DoTask(&summaryTask, opts)
+	// 任务出错时结果可能为空，类型不符时跳过，避免整次巡检中断
+	if summaryResult, ok := result.(*task.SummaryResult); ok {
+		resultSummary.VirtualResult = summaryResult
+	}
+	resultSummary.TaskRecords = append(resultSummary.TaskRecords, record)
+	// 执行组件依赖任务
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dbTask := task.DBTask{}
+	result, _, record = task.DoTask(&dbTask, opts)
+	if dbResult, ok := result.(*task.DBResult); ok && dbResult != nil {
+		resultSummary.DBResult = *dbResult
+	}
+	resultSummary.TaskRecords = append(resultSummary.TaskRecords, record)
+	// 执行证书检查任务
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tlsTask := task.TLSTask{}
+	result, abnormalResult, record := task.DoTask(&tlsTask, opts)
+	if certs, ok := result.([]task.TLSCertInfo); ok {
+		resultSummary.TLSResult = certs
+	}
+	resultSummary.TaskRecords = append(resultSummary.TaskRecords, record)
+	for _, msg := range abnormalResult {
+		resultSummary.SetAbnormalResult(msg)
+	}
+	// 执行 Celery 检查任务，会复用各 JumpServer 节点的连接
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	celeryTask := task.CeleryTask{}
+	result, abnormalResult, record = task.DoTask(&celeryTask, opts)
+	if celeryResult, ok := result.(*task.CeleryResult); ok && opts.EnableCelery {
+		resultSummary.CeleryResult = celeryResult
+	}
+	resultSummary.TaskRecords = append(resultSummary.TaskRecords, record)
+	for _, msg := range abnormalResult {
+		resultSummary.SetAbnormalResult(msg)
+	}
+	// 执行录像及命令存储检查任务
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	storageTask := task.StorageTask{}
+	result, abnormalResult, record = task.DoTask(&storageTask, opts)
+	if storages, ok := result.([]task.StorageInfo); ok {
+		resultSummary.StorageResult = storages
+	}
+	resultSummary.TaskRecords = append(resultSummary.TaskRecords, record)
+	for _, msg := range abnormalResult {
+		resultSummary.SetAbnormalResult(msg)
+	}
+
+	var resultList []task.MachineResult
+	for i := range opts.MachineSet {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		m := &opts.MachineSet[i]
+		// 每次巡检结束后会关闭连接，再次执行时需要重新连接，连接失败的机器本次跳过
+		if m.Client == nil {
+			if err := m.Connect(); err != nil {
+				m.Down()
+				logger.Warning("机器 %s(%s) 连接失败，本次巡检跳过: %s", m.Name, m.Host, err)
+				continue
+			}
+		}
+		executor := m.GetExecutor()
+		executor.Logger = logger
+		machineResult, abnormalResult := executor.Execute(opts)
+		resultList = append(resultList, *machineResult)
+		resultSummary.TaskRecords = append(resultSummary.TaskRecords, executor.TaskRecords...)
+		for _, msg := range abnormalResult {
+			msg.NodeName = m.Name
+			resultSummary.SetAbnormalResult(msg)
+		}
+	}
+	resultSummary.NormalResults = resultList
+	return &resultSummary, nil
+}
+
+// Run 完成一次完整的巡检，报告由调用方通过 report 包按需生成
+func Run(ctx context.Context, cfg Config) (*task.ResultSummary, error) {
+	opts, err := Prepare(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer opts.Clear()
+	return Execute(ctx, opts)
+}
@@ -0,0 +1,115 @@
+package inspect
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+// startFakeSSH 启动只接受连接的 SSH 服务，所有命令均返回空输出
+func startFakeSSH(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil },
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for newChannel := range channels {
+					channel, channelRequests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func(channel ssh.Channel, channelRequests <-chan *ssh.Request) {
+						for request := range channelRequests {
+							_ = request.Reply(request.Type == "exec", nil)
+							if request.Type != "exec" {
+								continue
+							}
+							_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+							_ = channel.Close()
+							return
+						}
+					}(channel, channelRequests)
+				}
+			}(conn)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+// 采集项与巡检并发执行时不能读写同一份机器连接，需配合 go test -race 运行
+func TestLiveCollectorWithExecute(t *testing.T) {
+	port := startFakeSSH(t)
+	opts := &task.Options{Logger: common.NopPrinter{}, JMSConfig: map[string]string{}}
+	for _, name := range []string{"jms1", "jms2"} {
+		opts.MachineSet = append(opts.MachineSet, task.Machine{
+			Name: name, Type: common.JumpServer, Host: "127.0.0.1", Port: port, Username: "root", Password: "x", Valid: true,
+		})
+	}
+	var collectors []task.LiveCollector
+	for _, collector := range task.LiveCollectors() {
+		if collector.Name == task.CollectorDisk || collector.Name == task.CollectorDocker {
+			collectors = append(collectors, collector)
+		}
+	}
+	snapshot := opts.LiveSnapshot()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, collector := range collectors {
+		wg.Add(1)
+		go func(collector task.LiveCollector) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if _, err := collector.Collect(snapshot); err != nil {
+					t.Errorf("采集项 %s 执行失败: %s", collector.Name, err)
+					return
+				}
+			}
+		}(collector)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := Execute(context.Background(), opts); err != nil {
+			t.Errorf("Execute: %s", err)
+		}
+	}
+	cancel()
+	wg.Wait()
+	for _, m := range snapshot.MachineSet {
+		if m.Client != nil {
+			t.Errorf("快照中的机器 %s 不应持有连接", m.Name)
+		}
+	}
+}
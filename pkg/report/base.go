@@ -8,17 +8,24 @@ import (
 )
 
 type BaseReport struct {
+	// 报告输出目录，生成报告时才创建
 	ReportDir  string
 	ReportPath string
 }
 
 func (r *BaseReport) GetReportFile(ext string) (*os.File, error) {
-	filename := fmt.Sprintf("JumpServer巡检报告_%s.%s", common.CurrentDatetime("file"), ext)
-	r.ReportDir = common.OutputDir
-	r.ReportPath = path.Join(common.OutputDir, filename)
+	return r.GetNamedReportFile("JumpServer巡检报告", ext)
+}
+
+func (r *BaseReport) GetNamedReportFile(name, ext string) (*os.File, error) {
+	filename := fmt.Sprintf("%s_%s.%s", common.T(name), common.CurrentDatetime("file"), ext)
+	if err := os.MkdirAll(r.ReportDir, 0700); err != nil {
+		return nil, err
+	}
+	r.ReportPath = path.Join(r.ReportDir, filename)
 	outputFile, err := os.Create(r.ReportPath)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	return outputFile, nil
 }
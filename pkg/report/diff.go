@@ -0,0 +1,388 @@
+package report
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+//go:embed templates/diff_report.html
+var diffTemplateData string
+
+// 参与对比的资源用量与运营指标
+var (
+	usageMetricNames = []string{
+		"disk_usage", "disk_used", "memory_used", "swap_used", "replay_used", "replay_unused",
+	}
+	countMetricNames = []string{
+		"user_count", "asset_count", "organization_count", "online_session_count",
+		"last1_month_login_count", "last1_month_connect_asset_count",
+		"last3_month_login_count", "last3_month_connect_asset_count",
+		"last3_month_command_count", "last3_month_danger_command_count",
+	}
+)
+
+type MetricDelta struct {
+	Node       string  `json:"node,omitempty"`
+	Name       string  `json:"name"`
+	Target     string  `json:"target,omitempty"`
+	Unit       string  `json:"unit"`
+	OldDisplay string  `json:"old_display"`
+	NewDisplay string  `json:"new_display"`
+	OldValue   float64 `json:"old_value"`
+	NewValue   float64 `json:"new_value"`
+	Delta      float64 `json:"delta"`
+}
+
+func (d MetricDelta) DeltaDisplay() string {
+	sign := ""
+	if d.Delta > 0 {
+		sign = "+"
+	}
+	switch d.Unit {
+	case task.UnitBytes:
+		if d.Delta < 0 {
+			return "-" + common.SpaceDisplay(int64(-d.Delta)/1024)
+		}
+		return sign + common.SpaceDisplay(int64(d.Delta)/1024)
+	case task.UnitPercent:
+		return fmt.Sprintf("%s%.1f%%", sign, d.Delta)
+	default:
+		return fmt.Sprintf("%s%g", sign, d.Delta)
+	}
+}
+
+type TableDelta struct {
+	TableName   string  `json:"table_name"`
+	OldRecord   string  `json:"old_record"`
+	NewRecord   string  `json:"new_record"`
+	RecordDelta float64 `json:"record_delta"`
+	OldSize     string  `json:"old_size"`
+	NewSize     string  `json:"new_size"`
+	SizeDelta   int64   `json:"size_delta_bytes"`
+}
+
+func (d TableDelta) SizeDeltaDisplay() string {
+	if d.SizeDelta < 0 {
+		return "-" + common.SpaceDisplay(-d.SizeDelta/1024)
+	}
+	return "+" + common.SpaceDisplay(d.SizeDelta/1024)
+}
+
+type ReportDiff struct {
+	OldInspectDatetime string `json:"old_inspect_datetime"`
+	NewInspectDatetime string `json:"new_inspect_datetime"`
+
+	NewAbnormal      []task.AbnormalMsg `json:"new_abnormal"`
+	ResolvedAbnormal []task.AbnormalMsg `json:"resolved_abnormal"`
+	LevelChanged     []AbnormalChange   `json:"level_changed_abnormal"`
+	AddedMachines    []string           `json:"added_machines"`
+	RemovedMachines  []string           `json:"removed_machines"`
+	UsageDeltas      []MetricDelta      `json:"usage_deltas"`
+	TableGrowth      []TableDelta       `json:"table_growth"`
+	CountDeltas      []MetricDelta      `json:"count_deltas"`
+}
+
+// 描述中按空白及标点切分出的词，其中的数值(含单位)、日期在对比时忽略
+var (
+	abnormalTokenRe = regexp.MustCompile(`[^\s:：,，;；、()（）]+`)
+	abnormalValueRe = regexp.MustCompile(`^(?:[0-9]+(?:\.[0-9]+)?(?:%|[KMGTPE]?i?B|[KMGTPE])?|[0-9]{4}-[0-9]{2}-[0-9]{2})$`)
+)
+
+// abnormalKey 只替换独立出现的数值，避免 "当前大小: 30GB" 这类数值变化被当做新异常，
+// 而 /data1、10.0.0.1、jms_koko1 等标识中的数字保持不变，等级变化单独对比
+func abnormalKey(msg task.AbnormalMsg) string {
+	desc := abnormalTokenRe.ReplaceAllStringFunc(msg.Desc, func(token string) string {
+		if abnormalValueRe.MatchString(token) {
+			return "#"
+		}
+		return token
+	})
+	return msg.NodeName + "|" + desc
+}
+
+type AbnormalChange struct {
+	Old task.AbnormalMsg `json:"old"`
+	New task.AbnormalMsg `json:"new"`
+}
+
+// diffAbnormal 先按原始描述、再按忽略数值后的描述一一配对，相同描述出现多次时按次数配对，
+// 未配对的新异常为新增，未配对的旧异常为已解决，配对后等级不同的为等级变化
+func diffAbnormal(oldMsgs, newMsgs []task.AbnormalMsg) (added, resolved []task.AbnormalMsg, changed []AbnormalChange) {
+	oldMatched, newMatched := make([]bool, len(oldMsgs)), make([]bool, len(newMsgs))
+	exactKey := func(msg task.AbnormalMsg) string {
+		return msg.NodeName + "|" + msg.Desc
+	}
+	for _, key := range []func(task.AbnormalMsg) string{exactKey, abnormalKey} {
+		pending := make(map[string][]int)
+		for i, msg := range oldMsgs {
+			if !oldMatched[i] {
+				pending[key(msg)] = append(pending[key(msg)], i)
+			}
+		}
+		for j, msg := range newMsgs {
+			indexes := pending[key(msg)]
+			if newMatched[j] || len(indexes) == 0 {
+				continue
+			}
+			i := indexes[0]
+			pending[key(msg)] = indexes[1:]
+			oldMatched[i], newMatched[j] = true, true
+			if oldMsgs[i].Level != msg.Level {
+				changed = append(changed, AbnormalChange{Old: oldMsgs[i], New: msg})
+			}
+		}
+	}
+	for j, msg := range newMsgs {
+		if !newMatched[j] {
+			added = append(added, msg)
+		}
+	}
+	for i, msg := range oldMsgs {
+		if !oldMatched[i] {
+			resolved = append(resolved, msg)
+		}
+	}
+	return added, resolved, changed
+}
+
+func diffMachines(oldSummary, newSummary *task.ResultSummary) (added, removed []string) {
+	oldSet, newSet := make(map[string]bool), make(map[string]bool)
+	for _, m := range oldSummary.NormalResults {
+		oldSet[m.MachineName] = true
+	}
+	for _, m := range newSummary.NormalResults {
+		newSet[m.MachineName] = true
+		if !oldSet[m.MachineName] {
+			added = append(added, m.MachineName)
+		}
+	}
+	for _, m := range oldSummary.NormalResults {
+		if !newSet[m.MachineName] {
+			removed = append(removed, m.MachineName)
+		}
+	}
+	return added, removed
+}
+
+func metricTarget(metric task.Metric) string {
+	keys := make([]string, 0, len(metric.Labels))
+	for key := range metric.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var values []string
+	for _, key := range keys {
+		values = append(values, metric.Labels[key])
+	}
+	return strings.Join(values, " ")
+}
+
+func diffMetrics(oldMetrics, newMetrics []task.Metric, names []string) []MetricDelta {
+	wanted := make(map[string]int)
+	for i, name := range names {
+		wanted[name] = i
+	}
+	key := func(m task.Metric) string {
+		return strings.Join([]string{m.Node, m.Name, metricTarget(m)}, "|")
+	}
+	oldMap := make(map[string]task.Metric)
+	for _, m := range oldMetrics {
+		oldMap[key(m)] = m
+	}
+	var deltas []MetricDelta
+	for _, m := range newMetrics {
+		if _, exist := wanted[m.Name]; !exist {
+			continue
+		}
+		old, exist := oldMap[key(m)]
+		if !exist || old.Value == m.Value {
+			continue
+		}
+		deltas = append(deltas, MetricDelta{
+			Node: m.Node, Name: m.Name, Target: metricTarget(m), Unit: m.Unit,
+			OldDisplay: old.Display, NewDisplay: m.Display,
+			OldValue: old.Value, NewValue: m.Value, Delta: m.Value - old.Value,
+		})
+	}
+	sort.SliceStable(deltas, func(i, j int) bool {
+		if deltas[i].Node != deltas[j].Node {
+			return deltas[i].Node < deltas[j].Node
+		}
+		if deltas[i].Name != deltas[j].Name {
+			return wanted[deltas[i].Name] < wanted[deltas[j].Name]
+		}
+		return deltas[i].Target < deltas[j].Target
+	})
+	return deltas
+}
+
+func diffTables(oldTables, newTables []task.TableInfo) []TableDelta {
+	oldMap := make(map[string]task.TableInfo)
+	for _, table := range oldTables {
+		oldMap[table.TableName] = table
+	}
+	var deltas []TableDelta
+	for _, table := range newTables {
+		old, exist := oldMap[table.TableName]
+		if !exist {
+			old = task.TableInfo{TableRecord: "0", TableSize: "0"}
+		}
+		oldRecord, _ := common.ParseCount(old.TableRecord)
+		newRecord, _ := common.ParseCount(table.TableRecord)
+		oldSize, _ := common.ParseSpace(old.TableSize)
+		newSize, _ := common.ParseSpace(table.TableSize)
+		deltas = append(deltas, TableDelta{
+			TableName: table.TableName,
+			OldRecord: old.TableRecord, NewRecord: table.TableRecord, RecordDelta: newRecord - oldRecord,
+			OldSize: old.TableSize, NewSize: table.TableSize, SizeDelta: newSize - oldSize,
+		})
+	}
+	sort.SliceStable(deltas, func(i, j int) bool {
+		return deltas[i].SizeDelta > deltas[j].SizeDelta
+	})
+	return deltas
+}
+
+func DiffReports(oldSummary, newSummary *task.ResultSummary) *ReportDiff {
+	diff := ReportDiff{
+		OldInspectDatetime: oldSummary.GlobalInfo.InspectDatetime,
+		NewInspectDatetime: newSummary.GlobalInfo.InspectDatetime,
+	}
+	diff.NewAbnormal, diff.ResolvedAbnormal, diff.LevelChanged = diffAbnormal(
+		oldSummary.AbnormalResults, newSummary.AbnormalResults,
+	)
+	diff.AddedMachines, diff.RemovedMachines = diffMachines(oldSummary, newSummary)
+	oldMetrics, newMetrics := oldSummary.Metrics(), newSummary.Metrics()
+	diff.UsageDeltas = diffMetrics(oldMetrics, newMetrics, usageMetricNames)
+	diff.CountDeltas = diffMetrics(oldMetrics, newMetrics, countMetricNames)
+	diff.TableGrowth = diffTables(oldSummary.DBResult.Top10Table, newSummary.DBResult.Top10Table)
+	return &diff
+}
+
+type DiffReport struct {
+	BaseReport
+
+	Diff *ReportDiff
+}
+
+func (r *DiffReport) GenerateHtml() error {
+	t, err := template.New("Template").Funcs(
+		template.FuncMap{
+			"Desc": task.MetricDescription, "Empty": common.InputOrEmpty, "T": common.T, "Tf": common.Tf,
+		},
+	).Parse(diffTemplateData)
+	if err != nil {
+		return err
+	}
+	outputFile, err := r.GetNamedReportFile("JumpServer巡检对比报告", "html")
+	if err != nil {
+		return err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+	return t.Execute(outputFile, r.Diff)
+}
+
+func (r *DiffReport) GenerateJson() error {
+	outputFile, err := r.GetNamedReportFile("JumpServer巡检对比报告", "json")
+	if err != nil {
+		return err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+	encoder := json.NewEncoder(outputFile)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.Diff)
+}
+
+func (r *DiffReport) GenerateMarkdown() error {
+	outputFile, err := r.GetNamedReportFile("JumpServer巡检对比报告", "md")
+	if err != nil {
+		return err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+
+	d := r.Diff
+	var b strings.Builder
+	b.WriteString("# " + common.T("JumpServer 巡检对比报告") + "\n\n")
+	b.WriteString(common.Tf("对比范围: %s → %s\n\n", d.OldInspectDatetime, d.NewInspectDatetime))
+
+	b.WriteString("## " + common.T("新增异常") + "\n\n")
+	b.WriteString(abnormalMarkdownTable(d.NewAbnormal))
+	b.WriteString("## " + common.T("已解决异常") + "\n\n")
+	b.WriteString(abnormalMarkdownTable(d.ResolvedAbnormal))
+	b.WriteString("## " + common.T("等级变化异常") + "\n\n")
+	var changeRows [][]string
+	for _, c := range d.LevelChanged {
+		changeRows = append(changeRows, []string{c.Old.LevelDisplay + " → " + c.New.LevelDisplay, c.New.NodeName, c.New.Desc})
+	}
+	b.WriteString(MarkdownTable([]string{"异常等级", "异常节点", "异常描述"}, changeRows))
+
+	b.WriteString("## " + common.T("机器变化") + "\n\n")
+	var machineRows [][]string
+	for _, name := range d.AddedMachines {
+		machineRows = append(machineRows, []string{"新增", name})
+	}
+	for _, name := range d.RemovedMachines {
+		machineRows = append(machineRows, []string{"移除", name})
+	}
+	b.WriteString(MarkdownTable([]string{"变化", "机器名"}, machineRows))
+
+	b.WriteString("## " + common.T("资源用量变化") + "\n\n")
+	b.WriteString(metricDeltaMarkdownTable(d.UsageDeltas))
+	b.WriteString("## " + common.T("数据库表增长") + "\n\n")
+	var tableRows [][]string
+	for _, t := range d.TableGrowth {
+		tableRows = append(tableRows, []string{
+			t.TableName, t.OldRecord, t.NewRecord, fmt.Sprintf("%+g", t.RecordDelta),
+			t.OldSize, t.NewSize, t.SizeDeltaDisplay(),
+		})
+	}
+	b.WriteString(MarkdownTable(
+		[]string{"表名", "原记录数", "现记录数", "记录数变化", "原大小", "现大小", "大小变化"}, tableRows,
+	))
+	b.WriteString("## " + common.T("运营数据变化") + "\n\n")
+	var countRows [][]string
+	for _, c := range d.CountDeltas {
+		countRows = append(countRows, []string{
+			task.MetricDescription(c.Name), c.OldDisplay, c.NewDisplay, c.DeltaDisplay(),
+		})
+	}
+	b.WriteString(MarkdownTable([]string{"指标", "原值", "现值", "变化"}, countRows))
+
+	_, err = outputFile.WriteString(b.String())
+	return err
+}
+
+func abnormalMarkdownTable(msgs []task.AbnormalMsg) string {
+	var rows [][]string
+	for _, msg := range msgs {
+		rows = append(rows, []string{msg.LevelDisplay, msg.NodeName, msg.Desc})
+	}
+	return MarkdownTable([]string{"异常等级", "异常节点", "异常描述"}, rows)
+}
+
+func metricDeltaMarkdownTable(deltas []MetricDelta) string {
+	var rows [][]string
+	for _, d := range deltas {
+		rows = append(rows, []string{
+			common.InputOrEmpty(d.Node), task.MetricDescription(d.Name), common.InputOrEmpty(d.Target),
+			d.OldDisplay, d.NewDisplay, d.DeltaDisplay(),
+		})
+	}
+	return MarkdownTable([]string{"节点", "指标", "对象", "原值", "现值", "变化"}, rows)
+}
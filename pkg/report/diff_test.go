@@ -0,0 +1,102 @@
+package report
+
+import (
+	"testing"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+func TestDiffAbnormal(t *testing.T) {
+	msg := func(level, node, desc string) task.AbnormalMsg {
+		return task.AbnormalMsg{Level: level, NodeName: node, Desc: desc}
+	}
+	descs := func(msgs []task.AbnormalMsg) []string {
+		var result []string
+		for _, m := range msgs {
+			result = append(result, m.Desc)
+		}
+		return result
+	}
+	cases := []struct {
+		name     string
+		old, new []task.AbnormalMsg
+		added    []string
+		resolved []string
+		changed  int
+	}{
+		{
+			name:  "数值变化不算新异常",
+			old:   []task.AbnormalMsg{msg(common.Critical, "a", "录像空间大小不足，当前大小: 30GB")},
+			new:   []task.AbnormalMsg{msg(common.Critical, "a", "录像空间大小不足，当前大小: 28.5GB")},
+			added: nil, resolved: nil,
+		},
+		{
+			name: "挂载点中的数字不被忽略",
+			old: []task.AbnormalMsg{
+				msg(common.Alert, "a", "/data1 磁盘空间不足 10%"), msg(common.Alert, "a", "/data2 磁盘空间不足 10%"),
+			},
+			new:      []task.AbnormalMsg{msg(common.Alert, "a", "/data2 磁盘空间不足 10%")},
+			resolved: []string{"/data1 磁盘空间不足 10%"},
+		},
+		{
+			name:     "IP 中的数字不被忽略",
+			old:      []task.AbnormalMsg{msg(common.Alert, "a", "组件 Core 健康检查失败(http://10.0.0.1:8080): 超时")},
+			new:      []task.AbnormalMsg{msg(common.Alert, "a", "组件 Core 健康检查失败(http://10.0.0.2:8080): 超时")},
+			added:    []string{"组件 Core 健康检查失败(http://10.0.0.2:8080): 超时"},
+			resolved: []string{"组件 Core 健康检查失败(http://10.0.0.1:8080): 超时"},
+		},
+		{
+			name: "容器名中的数字不被忽略",
+			old: []task.AbnormalMsg{
+				msg(common.Slight, "a", "容器 jms_koko1 已自动重启 2 次"), msg(common.Slight, "a", "容器 jms_koko2 已自动重启 2 次"),
+			},
+			new:      []task.AbnormalMsg{msg(common.Slight, "a", "容器 jms_koko2 已自动重启 3 次")},
+			resolved: []string{"容器 jms_koko1 已自动重启 2 次"},
+		},
+		{
+			name:     "相同描述按次数配对",
+			old:      []task.AbnormalMsg{msg(common.Normal, "a", "节点下存在僵尸进程"), msg(common.Normal, "a", "节点下存在僵尸进程")},
+			new:      []task.AbnormalMsg{msg(common.Normal, "a", "节点下存在僵尸进程")},
+			resolved: []string{"节点下存在僵尸进程"},
+		},
+		{
+			name:    "等级变化不算新增及已解决",
+			old:     []task.AbnormalMsg{msg(common.Alert, "a", "容器 jms_core 未运行，当前状态为 exited")},
+			new:     []task.AbnormalMsg{msg(common.Critical, "a", "容器 jms_core 未运行，当前状态为 exited")},
+			changed: 1,
+		},
+		{
+			name:     "不同节点的相同异常",
+			old:      []task.AbnormalMsg{msg(common.Critical, "a", "节点下防火墙未开启")},
+			new:      []task.AbnormalMsg{msg(common.Critical, "b", "节点下防火墙未开启")},
+			added:    []string{"节点下防火墙未开启"},
+			resolved: []string{"节点下防火墙未开启"},
+		},
+	}
+	equal := func(a, b []string) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			added, resolved, changed := diffAbnormal(c.old, c.new)
+			if !equal(descs(added), c.added) {
+				t.Errorf("added = %v, want %v", descs(added), c.added)
+			}
+			if !equal(descs(resolved), c.resolved) {
+				t.Errorf("resolved = %v, want %v", descs(resolved), c.resolved)
+			}
+			if len(changed) != c.changed {
+				t.Errorf("changed = %v, want %d", changed, c.changed)
+			}
+		})
+	}
+}
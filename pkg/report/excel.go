@@ -1,133 +1,369 @@
 package report
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"inspect/pkg/common"
-	"inspect/pkg/task"
 	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/tealeg/xlsx"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+const (
+	// Excel 限制工作表名称最长 31 个字符
+	excelSheetNameLimit = 31
+	excelMinColWidth    = 8
+	excelMaxColWidth    = 60
 )
 
+// 异常等级对应的行底色
+var levelFills = map[string]string{
+	common.Critical: "FFF8D7DA",
+	common.Alert:    "FFFFE5CC",
+	common.Normal:   "FFFFF3CD",
+	common.Slight:   "FFD1ECF1",
+}
+
 type ExcelReport struct {
 	BaseReport
 
 	Summary *task.ResultSummary
+
+	sheetNames map[string]bool
 }
 
-func (r *ExcelReport) WriteSheet(file *xlsx.File, sheetName string, data interface{}) error {
-	sheet, err := file.AddSheet(sheetName)
-	if err != nil {
-		return err
+func init() {
+	Register("xlsx", func(dir string, summary *task.ResultSummary) Reporter {
+		return &ExcelReport{BaseReport: BaseReport{ReportDir: dir}, Summary: summary}
+	})
+}
+
+func excelStyle(bold bool, fill string) *xlsx.Style {
+	style := xlsx.NewStyle()
+	style.Font.Bold = bold
+	style.ApplyFont = bold
+	style.Alignment.Vertical = "center"
+	style.Alignment.WrapText = true
+	style.ApplyAlignment = true
+	style.Border = *xlsx.NewBorder("thin", "thin", "thin", "thin")
+	style.ApplyBorder = true
+	if fill != "" {
+		style.Fill = *xlsx.NewFill("solid", fill, fill)
+		style.ApplyFill = true
+	}
+	return style
+}
+
+var (
+	excelHeaderStyle = excelStyle(true, "FFDDEBF7")
+	excelCellStyle   = excelStyle(false, "")
+	excelTitleStyle  = func() *xlsx.Style {
+		style := xlsx.NewStyle()
+		style.Font.Bold = true
+		style.Font.Size = 12
+		style.ApplyFont = true
+		return style
+	}()
+)
+
+// sheetName 去掉 Excel 不允许的字符并截断，重名时追加序号
+func (r *ExcelReport) sheetName(name string) string {
+	name = strings.Map(func(c rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, c) {
+			return '_'
+		}
+		return c
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Sheet"
+	}
+	truncate := func(s string, limit int) string {
+		runes := []rune(s)
+		if len(runes) > limit {
+			runes = runes[:limit]
+		}
+		return string(runes)
+	}
+	result := truncate(name, excelSheetNameLimit)
+	for i := 2; r.sheetNames[strings.ToLower(result)]; i++ {
+		suffix := fmt.Sprintf("(%d)", i)
+		result = truncate(name, excelSheetNameLimit-len(suffix)) + suffix
+	}
+	r.sheetNames[strings.ToLower(result)] = true
+	return result
+}
+
+func (r *ExcelReport) addSheet(file *xlsx.File, name string) (*xlsx.Sheet, error) {
+	return file.AddSheet(r.sheetName(common.T(name)))
+}
+
+func addRow(sheet *xlsx.Sheet, style *xlsx.Style, values ...string) *xlsx.Row {
+	row := sheet.AddRow()
+	for _, value := range values {
+		cell := row.AddCell()
+		cell.SetString(common.T(value))
+		if style != nil {
+			cell.SetStyle(style)
+		}
 	}
-	switch val := data.(type) {
-	case map[string]interface{}:
-		data = val
-	default:
-		return errors.New("unsupported data type")
-	}
-	for key, value := range data.(map[string]interface{}) {
-		var headCell *xlsx.Cell
-		row := sheet.AddRow()
-		switch val := value.(type) {
-		case []interface{}:
-			needMergeLength := 0
-			headCell = row.AddCell()
-			headCell.SetString(key)
-
-			for i, v1 := range val {
-				for k, v2 := range v1.(map[string]interface{}) {
-					needMergeLength += 1
-					if needMergeLength > 1 {
-						row = sheet.AddRow()
-						row.AddCell().Value = ""
+	return row
+}
+
+func addTitle(sheet *xlsx.Sheet, title string) {
+	if len(sheet.Rows) > 0 {
+		sheet.AddRow()
+	}
+	addRow(sheet, excelTitleStyle, title)
+}
+
+func addTable(sheet *xlsx.Sheet, headers []string, rows [][]string) {
+	addRow(sheet, excelHeaderStyle, headers...)
+	if len(rows) == 0 {
+		addRow(sheet, excelCellStyle, common.Empty)
+		return
+	}
+	for _, row := range rows {
+		addRow(sheet, excelCellStyle, row...)
+	}
+}
+
+func addKeyValue(sheet *xlsx.Sheet, rows [][]string) {
+	addTable(sheet, []string{"项目", "值"}, rows)
+}
+
+// fitColumns 按内容设置列宽，中文按两个字符宽度计算，空工作表直接跳过
+func fitColumns(sheet *xlsx.Sheet) {
+	var widths []int
+	for _, row := range sheet.Rows {
+		// 标题行只有一个单元格且不属于表格，不参与列宽计算
+		if len(row.Cells) < 2 {
+			continue
+		}
+		for i, cell := range row.Cells {
+			if i >= len(widths) {
+				widths = append(widths, excelMinColWidth)
+			}
+			width := 0
+			for _, line := range strings.Split(cell.String(), "\n") {
+				lineWidth := 0
+				for _, c := range line {
+					if utf8.RuneLen(c) > 1 {
+						lineWidth += 2
+					} else {
+						lineWidth += 1
 					}
-					row.AddCell().Value = k
-					row.AddCell().Value = fmt.Sprint(v2)
-				}
-				if i != len(val)-1 {
-					needMergeLength += 1
-					row = sheet.AddRow()
 				}
-			}
-			headCell.Merge(0, needMergeLength-1)
-		case map[string]interface{}:
-			needMergeLength := 0
-			headCell = row.AddCell()
-			headCell.SetString(key)
-			for k, v := range val {
-				needMergeLength += 1
-				if needMergeLength > 1 {
-					row = sheet.AddRow()
-					row.AddCell().Value = ""
+				if lineWidth > width {
+					width = lineWidth
 				}
-				row.AddCell().Value = k
-				row.AddCell().Value = fmt.Sprint(v)
 			}
-			headCell.Merge(0, needMergeLength-1)
-		default:
-			row.AddCell().Value = key
-			row.AddCell().Value = ""
-			if val == nil {
-				val = common.Empty
+			if width+2 > widths[i] {
+				widths[i] = width + 2
 			}
-			row.AddCell().Value = fmt.Sprint(val)
 		}
 	}
+	for i, width := range widths {
+		if width > excelMaxColWidth {
+			width = excelMaxColWidth
+		}
+		_ = sheet.SetColWidth(i, i, float64(width))
+	}
+}
 
-	for colIndex := 0; colIndex < len(sheet.Rows[0].Cells); colIndex++ {
-		maxWidth := 0
-		for _, row := range sheet.Rows {
-			if len(row.Cells) > colIndex {
-				cell := row.Cells[colIndex]
-				width := len([]rune(cell.String()))
-				if width > maxWidth {
-					maxWidth = width
-				}
-			}
+func (r *ExcelReport) writeOverview(file *xlsx.File) error {
+	sheet, err := r.addSheet(file, "巡检概述")
+	if err != nil {
+		return err
+	}
+	g := r.Summary.GlobalInfo
+	counts := make(map[string]int)
+	for _, msg := range r.Summary.AbnormalResults {
+		counts[msg.Level]++
+	}
+	addTitle(sheet, "巡检概述")
+	addKeyValue(sheet, [][]string{
+		{"巡检时间", g.InspectDatetime},
+		{"JumpServer 版本", g.JMSVersion},
+		{"巡检节点总数", fmt.Sprint(g.TotalCount)},
+		{"应用节点数", fmt.Sprint(g.JMSCount)},
+		{"数据库节点数", fmt.Sprint(g.RDSCount)},
+		{"Redis节点数", fmt.Sprint(g.RedisCount)},
+		{"严重异常数", fmt.Sprint(counts[common.Critical])},
+		{"警告异常数", fmt.Sprint(counts[common.Alert])},
+		{"一般异常数", fmt.Sprint(counts[common.Normal])},
+		{"轻微异常数", fmt.Sprint(counts[common.Slight])},
+	})
+	addTitle(sheet, "巡检机器")
+	addTable(sheet, globalHeaders, globalRows(g))
+	fitColumns(sheet)
+	return nil
+}
+
+func (r *ExcelReport) writeAbnormal(file *xlsx.File) error {
+	sheet, err := r.addSheet(file, "异常汇总")
+	if err != nil {
+		return err
+	}
+	headers := []string{"异常等级", "异常节点", "异常描述"}
+	addRow(sheet, excelHeaderStyle, headers...)
+	msgs := sortedAbnormal(r.Summary.AbnormalResults)
+	for _, msg := range msgs {
+		style := excelCellStyle
+		if fill, ok := levelFills[msg.Level]; ok {
+			style = excelStyle(false, fill)
 		}
-		sheet.Cols[colIndex].Width = float64(maxWidth)
+		addRow(sheet, style, msg.LevelDisplay, msg.NodeName, msg.Desc)
 	}
-	style := xlsx.NewStyle()
-	style.Alignment.Horizontal = "center"
-	style.Alignment.Vertical = "center"
-	sheet.Cols[0].SetStyle(style)
+	sheet.AutoFilter = &xlsx.AutoFilter{
+		TopLeftCell:     "A1",
+		BottomRightCell: fmt.Sprintf("%c%d", 'A'+len(headers)-1, len(msgs)+1),
+	}
+	fitColumns(sheet)
 	return nil
 }
 
-func (r *ExcelReport) Generate() error {
-	outputFile, err := r.GetReportFile("xlsx")
+func (r *ExcelReport) writeMachine(file *xlsx.File, m *task.MachineResult) error {
+	sheet, err := r.addSheet(file, m.MachineName)
 	if err != nil {
 		return err
 	}
+	addTitle(sheet, "系统信息")
+	addKeyValue(sheet, osInfoRows(m))
+	addTitle(sheet, "磁盘信息")
+	addTable(sheet, diskHeaders, diskRows(m))
+	if m.ServiceResult != nil {
+		addTitle(sheet, "JumpServer服务占用磁盘情况")
+		addKeyValue(sheet, serviceRows(m.ServiceResult))
+		addTitle(sheet, "JumpServer服务组件状态")
+		addTable(sheet, componentHeaders, componentRows(m.ServiceResult))
+		if rows := containerRows(m.ServiceResult); len(rows) > 0 {
+			addTitle(sheet, "JumpServer容器详情")
+			addTable(sheet, containerHeaders, rows)
+		}
+		if rows := logErrorRows(m.ServiceResult); len(rows) > 0 {
+			addTitle(sheet, "JumpServer组件日志错误")
+			addTable(sheet, logErrorHeaders, rows)
+		}
+	}
+	fitColumns(sheet)
+	return nil
+}
 
-	defer func(outputFile *os.File) {
-		_ = outputFile.Close()
-	}(outputFile)
+func (r *ExcelReport) writeDB(file *xlsx.File) error {
+	db := &r.Summary.DBResult
+	if !db.HasRDSInfo && !db.HasRedisInfo {
+		return nil
+	}
+	sheet, err := r.addSheet(file, "数据库")
+	if err != nil {
+		return err
+	}
+	if db.HasRDSInfo {
+		addTitle(sheet, "RDS 状态")
+		addKeyValue(sheet, rdsRows(db))
+		addTitle(sheet, "数据库表大小前10位")
+		addTable(sheet, tableHeaders, tableRows(db))
+	}
+	if db.HasRedisInfo {
+		addTitle(sheet, "Redis 状态")
+		addKeyValue(sheet, redisRows(&db.RedisResult))
+	}
+	fitColumns(sheet)
+	return nil
+}
 
-	file := xlsx.NewFile()
+func (r *ExcelReport) writeTLS(file *xlsx.File) error {
+	if len(r.Summary.TLSResult) == 0 {
+		return nil
+	}
+	sheet, err := r.addSheet(file, "TLS 证书")
+	if err != nil {
+		return err
+	}
+	addTable(sheet, tlsHeaders, tlsRows(r.Summary.TLSResult))
+	fitColumns(sheet)
+	return nil
+}
 
-	var data map[string]interface{}
-	jsonData, err := json.Marshal(r.Summary)
-	err = json.Unmarshal(jsonData, &data)
-	for name, value := range data {
-		if value == nil {
-			continue
-		}
-		switch val := value.(type) {
-		case []interface{}:
-			for i, v1 := range val {
-				err = r.WriteSheet(file, fmt.Sprintf("%s-%v", name, i), v1)
-			}
-		default:
-			err = r.WriteSheet(file, name, val)
-		}
+func (r *ExcelReport) writeCelery(file *xlsx.File) error {
+	c := r.Summary.CeleryResult
+	if c == nil {
+		return nil
+	}
+	sheet, err := r.addSheet(file, "Celery 任务")
+	if err != nil {
+		return err
+	}
+	addTitle(sheet, "Celery 运行状态")
+	addTable(sheet, celeryHeaders, celeryRows(c))
+	addTitle(sheet, "周期任务")
+	if c.PeriodicError != "" {
+		addKeyValue(sheet, [][]string{{"查询失败", c.PeriodicError}})
+	} else {
+		addTable(sheet, periodicHeaders, periodicRows(c))
+	}
+	fitColumns(sheet)
+	return nil
+}
+
+func (r *ExcelReport) writeStorage(file *xlsx.File) error {
+	if len(r.Summary.StorageResult) == 0 {
+		return nil
+	}
+	sheet, err := r.addSheet(file, "录像及命令存储")
+	if err != nil {
+		return err
+	}
+	addTable(sheet, storageHeaders, storageRows(r.Summary.StorageResult))
+	fitColumns(sheet)
+	return nil
+}
+
+func (r *ExcelReport) writeSummary(file *xlsx.File) error {
+	v := r.Summary.VirtualResult
+	if v == nil {
+		return nil
 	}
+	sheet, err := r.addSheet(file, "运营巡检")
 	if err != nil {
 		return err
 	}
-	_ = file.Write(outputFile)
+	addTitle(sheet, summaryOverview(v))
+	addKeyValue(sheet, summaryRows(v))
+	fitColumns(sheet)
 	return nil
 }
+
+func (r *ExcelReport) Generate() ([]string, error) {
+	r.sheetNames = make(map[string]bool)
+	file := xlsx.NewFile()
+	// 工作表顺序固定，保证每次生成的报告结构一致
+	writers := []func(file *xlsx.File) error{r.writeOverview, r.writeAbnormal}
+	for i := range r.Summary.NormalResults {
+		m := &r.Summary.NormalResults[i]
+		writers = append(writers, func(file *xlsx.File) error {
+			return r.writeMachine(file, m)
+		})
+	}
+	writers = append(writers, r.writeDB, r.writeSummary, r.writeTLS, r.writeCelery, r.writeStorage)
+	for _, write := range writers {
+		if err := write(file); err != nil {
+			return nil, err
+		}
+	}
+
+	outputFile, err := r.GetReportFile("xlsx")
+	if err != nil {
+		return nil, err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+	if err = file.Write(outputFile); err != nil {
+		return nil, err
+	}
+	return []string{r.ReportPath}, nil
+}
@@ -0,0 +1,177 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+const (
+	HistoryFileName = "history.jsonl"
+	// 趋势图最多展示的巡检次数
+	TrendLimit = 30
+)
+
+// HistoryRecord 每次巡检的指标快照，按行追加到输出根目录的 history.jsonl 中
+type HistoryRecord struct {
+	InspectTime     time.Time     `json:"inspect_time"`
+	InspectDatetime string        `json:"inspect_datetime"`
+	JMSVersion      string        `json:"jms_version"`
+	ReportDir       string        `json:"report_dir"`
+	Metrics         []task.Metric `json:"metrics"`
+}
+
+func (r *HistoryRecord) get(name string, match func(m task.Metric) bool) (float64, bool) {
+	for _, m := range r.Metrics {
+		if m.Name == name && (match == nil || match(m)) {
+			return m.Value, true
+		}
+	}
+	return 0, false
+}
+
+// HistoryPath 历史记录保存在报告目录的上一级，即输出根目录
+func HistoryPath(reportDir string) string {
+	return filepath.Join(filepath.Dir(reportDir), HistoryFileName)
+}
+
+func LoadHistory(reportDir string) ([]HistoryRecord, error) {
+	file, err := os.Open(HistoryPath(reportDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record HistoryRecord
+		// 单行损坏时跳过，不影响其他历史记录
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].InspectTime.Before(records[j].InspectTime)
+	})
+	return records, scanner.Err()
+}
+
+// AppendHistory 记录本次巡检的指标，并返回包含本次在内的全部历史记录
+func AppendHistory(reportDir string, summary *task.ResultSummary) ([]HistoryRecord, error) {
+	record := HistoryRecord{
+		InspectTime:     summary.GlobalInfo.InspectTime,
+		InspectDatetime: summary.GlobalInfo.InspectDatetime,
+		JMSVersion:      summary.GlobalInfo.JMSVersion,
+		ReportDir:       reportDir,
+		Metrics:         summary.Metrics(),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	historyPath := HistoryPath(reportDir)
+	if err = os.MkdirAll(filepath.Dir(historyPath), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return LoadHistory(reportDir)
+}
+
+type trendSeries struct {
+	Name string     `json:"name"`
+	Data []*float64 `json:"data"`
+}
+
+type trendData struct {
+	X []string `json:"x"`
+	// 按机器名排序，保证每次生成的图例顺序一致
+	Disk     []trendSeries         `json:"disk"`
+	DBSize   []*float64            `json:"db_size"`
+	Session  map[string][]*float64 `json:"session"`
+	Abnormal map[string][]*float64 `json:"abnormal"`
+}
+
+// BuildTrendData 生成 HTML 报告中历史趋势图所需的数据，缺失的指标以 null 占位
+func BuildTrendData(records []HistoryRecord) string {
+	if len(records) > TrendLimit {
+		records = records[len(records)-TrendLimit:]
+	}
+	data := trendData{
+		Session:  make(map[string][]*float64),
+		Abnormal: make(map[string][]*float64),
+	}
+	value := func(v float64, ok bool) *float64 {
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, record := range records {
+		for _, m := range record.Metrics {
+			if m.Name == "disk_usage" && !seen[m.Node] {
+				seen[m.Node] = true
+				nodes = append(nodes, m.Node)
+			}
+		}
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		data.Disk = append(data.Disk, trendSeries{Name: node, Data: make([]*float64, len(records))})
+	}
+	for i, record := range records {
+		data.X = append(data.X, record.InspectTime.Local().Format("2006-01-02 15:04"))
+		for j, node := range nodes {
+			// 每台机器取使用率最高的挂载点
+			var maxUsage float64
+			var exist bool
+			for _, m := range record.Metrics {
+				if m.Name == "disk_usage" && m.Node == node && (!exist || m.Value > maxUsage) {
+					maxUsage, exist = m.Value, true
+				}
+			}
+			data.Disk[j].Data[i] = value(maxUsage, exist)
+		}
+		size, ok := record.get("db_size", nil)
+		data.DBSize = append(data.DBSize, value(size/1024/1024, ok))
+		for _, name := range []string{"online_session_count", "last1_month_connect_asset_count"} {
+			data.Session[name] = append(data.Session[name], value(record.get(name, nil)))
+		}
+		for _, level := range []string{common.Critical, common.Alert, common.Normal, common.Slight} {
+			count, ok := record.get("abnormal_count", func(m task.Metric) bool {
+				return m.Labels["level"] == level
+			})
+			data.Abnormal[level] = append(data.Abnormal[level], value(count, ok))
+		}
+	}
+	result, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	return string(result)
+}
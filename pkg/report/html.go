@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"text/template"
 
+	"inspect/pkg/common"
 	"inspect/pkg/task"
 )
 
@@ -35,6 +36,12 @@ type HtmlReport struct {
 	Summary *task.ResultSummary
 }
 
+func init() {
+	Register("html", func(dir string, summary *task.ResultSummary) Reporter {
+		return &HtmlReport{BaseReport: BaseReport{ReportDir: dir}, Summary: summary}
+	})
+}
+
 func Add(num1, num2 int) int {
 	return num1 + num2
 }
@@ -47,22 +54,22 @@ func Json(body any) string {
 	}
 }
 
-func (r *HtmlReport) Generate() error {
+func (r *HtmlReport) Generate() ([]string, error) {
 	pager := PageManager{num: 0}
 
 	t, err := template.New("Template").Funcs(
 		template.FuncMap{
 			"Add": Add, "Json": Json, "GetPage": pager.GetPage,
-			"CalcPage": pager.CalcPage,
+			"CalcPage": pager.CalcPage, "T": common.T, "Tf": common.Tf,
 		},
 	).Parse(templateData)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	outputFile, err := r.GetReportFile("html")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func(outputFile *os.File) {
 		_ = outputFile.Close()
@@ -70,7 +77,7 @@ func (r *HtmlReport) Generate() error {
 	r.Summary.EchartsData = echartsData
 	err = t.Execute(outputFile, r.Summary)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return []string{r.ReportPath}, nil
 }
@@ -1,30 +1,152 @@
 package report
 
 import (
+	_ "embed"
 	"encoding/json"
+	"fmt"
+	"inspect/pkg/common"
 	"inspect/pkg/task"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
+// JsonSchemaVersion JSON 报告结构版本，字段有不兼容变更时需要升级主版本号
+const JsonSchemaVersion = "2.0"
+
+//go:embed schema/report.schema.json
+var JsonSchema []byte
+
+type JsonDocument struct {
+	SchemaVersion string    `json:"schema_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+
+	*task.ResultSummary
+
+	Metrics []task.Metric `json:"metrics"`
+}
+
 type JsonReport struct {
 	BaseReport
 
 	Summary *task.ResultSummary
 }
 
-func (r *JsonReport) Generate() error {
+func init() {
+	Register("json", func(dir string, summary *task.ResultSummary) Reporter {
+		return &JsonReport{BaseReport: BaseReport{ReportDir: dir}, Summary: summary}
+	})
+}
+
+func (r *JsonReport) Generate() ([]string, error) {
 	outputFile, err := r.GetReportFile("json")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func(outputFile *os.File) {
 		_ = outputFile.Close()
 	}(outputFile)
 
+	document := JsonDocument{
+		SchemaVersion: JsonSchemaVersion,
+		GeneratedAt:   time.Now(),
+		ResultSummary: r.Summary,
+		Metrics:       r.Summary.Metrics(),
+	}
 	encoder := json.NewEncoder(outputFile)
 	encoder.SetEscapeHTML(false)
-	if err = encoder.Encode(r.Summary); err != nil {
-		return err
+	if err = encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	return []string{r.ReportPath}, nil
+}
+
+func ValidateJsonReport(filepath string) error {
+	compiler := jsonschema.NewCompiler()
+	schemaURL := "report.schema.json"
+	if err := compiler.AddResource(schemaURL, strings.NewReader(string(JsonSchema))); err != nil {
+		return fmt.Errorf(common.T("加载 JSON Schema 失败: %w"), err)
+	}
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return fmt.Errorf(common.T("编译 JSON Schema 失败: %w"), err)
+	}
+
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return fmt.Errorf(common.T("读取报告文件 %s 失败: %w"), filepath, err)
+	}
+	var document interface{}
+	if err = json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf(common.T("报告文件 %s 不是合法的 JSON: %w"), filepath, err)
+	}
+	return schema.Validate(document)
+}
+
+// LoadJsonReport 读取 JSON 格式报告并还原为巡检结果，用于离线重新生成其他格式报告
+func LoadJsonReport(filepath string) (*task.ResultSummary, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf(common.T("读取报告文件 %s 失败: %w"), filepath, err)
+	}
+	var version struct {
+		SchemaVersion string `json:"schema_version"`
+	}
+	if err = json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf(common.T("解析报告文件 %s 失败: %w"), filepath, err)
+	}
+	major := strings.SplitN(JsonSchemaVersion, ".", 2)[0]
+	documentMajor := strings.SplitN(version.SchemaVersion, ".", 2)[0]
+	if documentMajor == "1" {
+		if data, err = migrateV1Report(data); err != nil {
+			return nil, fmt.Errorf(common.T("解析报告文件 %s 失败: %w"), filepath, err)
+		}
+		documentMajor = major
+	}
+	document := JsonDocument{ResultSummary: &task.ResultSummary{}}
+	if err = json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf(common.T("解析报告文件 %s 失败: %w"), filepath, err)
+	}
+	if documentMajor != major {
+		return nil, fmt.Errorf(
+			common.T("报告文件 %s 的结构版本为 %q，当前仅支持 %s.x 版本"),
+			filepath, version.SchemaVersion, major,
+		)
+	}
+	return document.ResultSummary, nil
+}
+
+// migrateV1Report 1.x 版本的平均会话时长以字符串保存在 *_seconds 字段中，2.0 起该字段为秒数
+func migrateV1Report(data []byte) ([]byte, error) {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	var virtual map[string]interface{}
+	if raw, ok := document["virtual_result"]; !ok || json.Unmarshal(raw, &virtual) != nil || virtual == nil {
+		return data, nil
+	}
+	const oldKey = "last3_month_avg_session_duration_seconds"
+	if display, ok := virtual[oldKey].(string); ok {
+		delete(virtual, oldKey)
+		virtual["last3_month_avg_session_duration"] = display
+		if seconds, err := strconv.ParseFloat(display, 64); err == nil {
+			virtual[oldKey] = int64(seconds)
+			virtual["last3_month_avg_session_duration"] = common.SecondDisplay(int(seconds))
+		}
+	}
+	if display, ok := virtual["last3_month_max_session_duration"].(string); ok {
+		if seconds, ok := common.ParseClockDuration(display); ok {
+			virtual["last3_month_max_session_duration_seconds"] = seconds
+		}
+	}
+	raw, err := json.Marshal(virtual)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	document["virtual_result"] = raw
+	return json.Marshal(document)
 }
@@ -0,0 +1,204 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+// JUnit 报告供 CI 使用：每台机器对应一个 testsuite，每个巡检任务及每条异常各对应一个 testcase，
+// 任务执行出错记为 error，严重及警告级别的异常记为 failure，
+// 不属于某台机器的全局任务单独放在 package 不同的 testsuite 中，避免与同名机器合并
+
+const (
+	junitMachinePackage = "jms_inspect.machine"
+	junitGlobalPackage  = "jms_inspect.global"
+)
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Package   string          `xml:"package,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+
+	duration float64
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type JUnitReport struct {
+	BaseReport
+
+	Summary *task.ResultSummary
+}
+
+func init() {
+	Register("junit", func(dir string, summary *task.ResultSummary) Reporter {
+		return &JUnitReport{BaseReport: BaseReport{ReportDir: dir}, Summary: summary}
+	})
+}
+
+func junitSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// isJUnitFailure 仅严重及警告级别的异常会导致用例失败
+func isJUnitFailure(level string) bool {
+	return level == common.Critical || level == common.Alert
+}
+
+func junitAbnormalLines(msgs []task.AbnormalMsg) string {
+	lines := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		lines = append(lines, fmt.Sprintf("[%s] %s", common.T(msg.LevelDisplay), common.T(msg.Desc)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// suitePackage 全局任务与机器使用不同的 package，机器名与全局任务的 testsuite 名称相同时也不会合并
+func (r *JUnitReport) suitePackage(nodeName string) string {
+	if nodeName == "" {
+		return junitGlobalPackage
+	}
+	return junitMachinePackage
+}
+
+func (r *JUnitReport) suiteName(nodeName string) string {
+	if nodeName == "" {
+		return common.T("全局任务")
+	}
+	return nodeName
+}
+
+// buildSuites 按机器归集任务记录和异常：每个任务对应一个用例，任务出错时记为 error；
+// 每条异常单独对应一个用例，严重及警告记为 failure，其他级别只记录在输出中
+func (r *JUnitReport) buildSuites() []junitTestSuite {
+	var suiteOrder []string
+	suiteCases := make(map[string][]junitTestCase)
+	durations := make(map[string]float64)
+	addCase := func(node string, testCase junitTestCase) {
+		if _, exist := suiteCases[node]; !exist {
+			suiteOrder = append(suiteOrder, node)
+		}
+		testCase.ClassName = r.suitePackage(node) + "." + r.suiteName(node)
+		suiteCases[node] = append(suiteCases[node], testCase)
+	}
+
+	for _, record := range r.Summary.TaskRecords {
+		testCase := junitTestCase{Name: common.T(record.Name), Time: junitSeconds(record.Duration)}
+		if record.Error != "" {
+			testCase.Error = &junitFailure{Message: record.Error, Type: "TaskError", Content: record.Error}
+		}
+		addCase(record.NodeName, testCase)
+		durations[record.NodeName] += record.Duration
+	}
+	for _, m := range r.Summary.NormalResults {
+		if _, exist := suiteCases[m.MachineName]; !exist {
+			addCase(m.MachineName, junitTestCase{Name: common.T("巡检"), Time: junitSeconds(0)})
+		}
+	}
+	for _, msg := range sortedAbnormal(r.Summary.AbnormalResults) {
+		taskName := msg.Task
+		if taskName == "" {
+			taskName = "巡检"
+		}
+		desc := common.T(msg.Desc)
+		testCase := junitTestCase{
+			Name: fmt.Sprintf("%s: %s", common.T(taskName), desc), Time: junitSeconds(0),
+		}
+		if isJUnitFailure(msg.Level) {
+			testCase.Failure = &junitFailure{Message: desc, Type: msg.Level, Content: junitAbnormalLines([]task.AbnormalMsg{msg})}
+		} else {
+			testCase.SystemOut = junitAbnormalLines([]task.AbnormalMsg{msg})
+		}
+		addCase(msg.NodeName, testCase)
+	}
+
+	timestamp := ""
+	if !r.Summary.GlobalInfo.InspectTime.IsZero() {
+		timestamp = r.Summary.GlobalInfo.InspectTime.Format("2006-01-02T15:04:05")
+	}
+	var suites []junitTestSuite
+	for _, node := range suiteOrder {
+		suite := junitTestSuite{
+			Name: r.suiteName(node), Package: r.suitePackage(node), Timestamp: timestamp,
+			TestCases: suiteCases[node], duration: durations[node],
+		}
+		for _, testCase := range suite.TestCases {
+			if testCase.Error != nil {
+				suite.Errors++
+			} else if testCase.Failure != nil {
+				suite.Failures++
+			}
+		}
+		suite.Tests = len(suite.TestCases)
+		suite.Time = junitSeconds(suite.duration)
+		suites = append(suites, suite)
+	}
+	return suites
+}
+
+func (r *JUnitReport) Generate() ([]string, error) {
+	outputFile, err := r.GetReportFile("xml")
+	if err != nil {
+		return nil, err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+
+	document := junitTestSuites{Name: common.T("JumpServer 巡检报告")}
+	var duration float64
+	for _, suite := range r.buildSuites() {
+		document.Tests += suite.Tests
+		document.Failures += suite.Failures
+		document.Errors += suite.Errors
+		duration += suite.duration
+		document.Suites = append(document.Suites, suite)
+	}
+	document.Time = junitSeconds(duration)
+
+	if _, err = outputFile.WriteString(xml.Header); err != nil {
+		return nil, err
+	}
+	encoder := xml.NewEncoder(outputFile)
+	encoder.Indent("", "  ")
+	if err = encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	if _, err = outputFile.WriteString("\n"); err != nil {
+		return nil, err
+	}
+	return []string{r.ReportPath}, nil
+}
@@ -0,0 +1,47 @@
+package report
+
+import (
+	"testing"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+func TestJUnitSuites(t *testing.T) {
+	summary := &task.ResultSummary{
+		NormalResults: []task.MachineResult{{MachineType: "jumpserver", MachineName: "JumpServer"}},
+		TaskRecords: []task.TaskRecord{
+			{Name: "数据库", Error: "dial tcp: refused"},
+			{Name: "机器当前系统检查", NodeName: "JumpServer"},
+		},
+		AbnormalResults: []task.AbnormalMsg{
+			{Level: common.Critical, NodeName: "JumpServer", Desc: "节点下防火墙未开启", Task: "机器当前系统检查"},
+			{Level: common.Normal, NodeName: "JumpServer", Desc: "节点下存在僵尸进程", Task: "机器当前系统检查"},
+		},
+	}
+	r := JUnitReport{Summary: summary}
+	suites := r.buildSuites()
+	if len(suites) != 2 {
+		t.Fatalf("len(suites) = %d, want 2", len(suites))
+	}
+	cases := []struct {
+		suite    junitTestSuite
+		pkg      string
+		tests    int
+		errors   int
+		failures int
+	}{
+		{suites[0], junitGlobalPackage, 1, 1, 0},
+		{suites[1], junitMachinePackage, 3, 0, 1},
+	}
+	for _, c := range cases {
+		if c.suite.Package != c.pkg || c.suite.Tests != c.tests || c.suite.Errors != c.errors || c.suite.Failures != c.failures {
+			t.Errorf("suite %s = %s tests=%d errors=%d failures=%d, want %s tests=%d errors=%d failures=%d",
+				c.suite.Name, c.suite.Package, c.suite.Tests, c.suite.Errors, c.suite.Failures,
+				c.pkg, c.tests, c.errors, c.failures)
+		}
+	}
+	if global, machine := suites[0].TestCases[0].ClassName, suites[1].TestCases[0].ClassName; global == machine {
+		t.Errorf("全局任务与同名机器的 classname 相同: %s", global)
+	}
+}
@@ -0,0 +1,182 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+func markdownEscape(value string) string {
+	value = strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(value)
+	value = strings.ReplaceAll(value, "|", "\\|")
+	value = strings.ReplaceAll(value, "\r\n", "<br>")
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+// MarkdownTable 生成 GitHub 风格的 Markdown 表格，无数据时输出提示文字
+func MarkdownTable(headers []string, rows [][]string) string {
+	if len(rows) == 0 {
+		return common.T("无内容") + "\n\n"
+	}
+	var b strings.Builder
+	titles := make([]string, len(headers))
+	for i, header := range headers {
+		titles[i] = common.T(header)
+	}
+	b.WriteString("| " + strings.Join(titles, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(headers)) + "\n")
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = markdownEscape(common.T(cell))
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func markdownKeyValue(rows [][]string) string {
+	return MarkdownTable([]string{"项目", "值"}, rows)
+}
+
+type MarkdownReport struct {
+	BaseReport
+
+	Summary *task.ResultSummary
+}
+
+func init() {
+	Register("md", func(dir string, summary *task.ResultSummary) Reporter {
+		return &MarkdownReport{BaseReport: BaseReport{ReportDir: dir}, Summary: summary}
+	})
+}
+
+func (r *MarkdownReport) writeGlobal(b *strings.Builder) {
+	g := r.Summary.GlobalInfo
+	b.WriteString("## " + common.T("1. 巡检概述") + "\n\n")
+	b.WriteString(common.Tf(
+		"巡检时间: %s，JumpServer 版本: %s。本次巡检共巡检 %d 个节点，其中应用节点 %d 个，数据库节点 %d 个，Redis节点 %d 个。\n\n",
+		g.InspectDatetime, g.JMSVersion, g.TotalCount, g.JMSCount, g.RDSCount, g.RedisCount,
+	))
+	b.WriteString(MarkdownTable(globalHeaders, globalRows(g)))
+}
+
+func (r *MarkdownReport) writeAbnormal(b *strings.Builder) {
+	b.WriteString("## " + common.T("2. 异常汇总") + "\n\n")
+	b.WriteString(abnormalMarkdownTable(sortedAbnormal(r.Summary.AbnormalResults)))
+}
+
+func (r *MarkdownReport) writeMachine(b *strings.Builder, index int, m *task.MachineResult) {
+	b.WriteString(fmt.Sprintf("### 3.%d %s\n\n", index+1, m.MachineName))
+	b.WriteString("#### " + common.T("系统信息") + "\n\n")
+	b.WriteString(markdownKeyValue(osInfoRows(m)))
+	b.WriteString("#### " + common.T("磁盘信息") + "\n\n")
+	b.WriteString(MarkdownTable(diskHeaders, diskRows(m)))
+	if m.ServiceResult == nil {
+		return
+	}
+	b.WriteString("#### " + common.T("JumpServer服务占用磁盘情况") + "\n\n")
+	b.WriteString(markdownKeyValue(serviceRows(m.ServiceResult)))
+	b.WriteString("#### " + common.T("JumpServer服务组件状态") + "\n\n")
+	b.WriteString(MarkdownTable(componentHeaders, componentRows(m.ServiceResult)))
+	if rows := containerRows(m.ServiceResult); len(rows) > 0 {
+		b.WriteString("#### " + common.T("JumpServer容器详情") + "\n\n")
+		b.WriteString(MarkdownTable(containerHeaders, rows))
+	}
+	if rows := logErrorRows(m.ServiceResult); len(rows) > 0 {
+		b.WriteString("#### " + common.T("JumpServer组件日志错误") + "\n\n")
+		b.WriteString(MarkdownTable(logErrorHeaders, rows))
+	}
+}
+
+func (r *MarkdownReport) writeDB(b *strings.Builder) {
+	db := &r.Summary.DBResult
+	if !db.HasRDSInfo && !db.HasRedisInfo {
+		return
+	}
+	b.WriteString("## " + common.T("4. 数据库") + "\n\n")
+	if db.HasRDSInfo {
+		b.WriteString("### " + common.T("4.1 RDS 状态") + "\n\n")
+		b.WriteString(markdownKeyValue(rdsRows(db)))
+		b.WriteString("### " + common.T("4.2 数据库表大小前10位") + "\n\n")
+		b.WriteString(MarkdownTable(tableHeaders, tableRows(db)))
+	}
+	if db.HasRedisInfo {
+		b.WriteString("### " + common.T("4.3 Redis 状态") + "\n\n")
+		b.WriteString(markdownKeyValue(redisRows(&db.RedisResult)))
+	}
+}
+
+func (r *MarkdownReport) writeSummary(b *strings.Builder) {
+	v := r.Summary.VirtualResult
+	if v == nil {
+		return
+	}
+	b.WriteString("## " + common.T("5. 运营巡检") + "\n\n")
+	b.WriteString(summaryOverview(v) + "\n\n")
+	b.WriteString(markdownKeyValue(summaryRows(v)))
+}
+
+func (r *MarkdownReport) writeTLS(b *strings.Builder) {
+	if len(r.Summary.TLSResult) == 0 {
+		return
+	}
+	b.WriteString("## " + common.T("6. TLS 证书") + "\n\n")
+	b.WriteString(MarkdownTable(tlsHeaders, tlsRows(r.Summary.TLSResult)))
+}
+
+func (r *MarkdownReport) writeCelery(b *strings.Builder) {
+	c := r.Summary.CeleryResult
+	if c == nil {
+		return
+	}
+	b.WriteString("## " + common.T("7. Celery 任务") + "\n\n")
+	b.WriteString("### " + common.T("Celery 运行状态") + "\n\n")
+	b.WriteString(MarkdownTable(celeryHeaders, celeryRows(c)))
+	b.WriteString("### " + common.T("周期任务") + "\n\n")
+	if c.PeriodicError != "" {
+		b.WriteString(common.Tf("查询失败: %s", c.PeriodicError) + "\n\n")
+		return
+	}
+	b.WriteString(MarkdownTable(periodicHeaders, periodicRows(c)))
+}
+
+func (r *MarkdownReport) writeStorage(b *strings.Builder) {
+	if len(r.Summary.StorageResult) == 0 {
+		return
+	}
+	b.WriteString("## " + common.T("8. 录像及命令存储") + "\n\n")
+	b.WriteString(MarkdownTable(storageHeaders, storageRows(r.Summary.StorageResult)))
+}
+
+func (r *MarkdownReport) Generate() ([]string, error) {
+	outputFile, err := r.GetReportFile("md")
+	if err != nil {
+		return nil, err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+
+	var b strings.Builder
+	b.WriteString("# " + common.T("JumpServer 巡检报告") + "\n\n")
+	r.writeGlobal(&b)
+	r.writeAbnormal(&b)
+	b.WriteString("## " + common.T("3. 机器详情") + "\n\n")
+	for i := range r.Summary.NormalResults {
+		r.writeMachine(&b, i, &r.Summary.NormalResults[i])
+	}
+	r.writeDB(&b)
+	r.writeSummary(&b)
+	r.writeTLS(&b)
+	r.writeCelery(&b)
+	r.writeStorage(&b)
+	if _, err = outputFile.WriteString(b.String()); err != nil {
+		return nil, err
+	}
+	return []string{r.ReportPath}, nil
+}
@@ -0,0 +1,571 @@
+package report
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+// 中文字体在编译时由 build.sh 下载，PDF 中只嵌入实际用到的字形
+//
+//go:embed templates/LXGWWenKai-Regular.ttf
+var pdfFontData []byte
+
+const (
+	pdfFontFamily = "cjk"
+	pdfLineHeight = 5.5
+	pdfCellPad    = 1.5
+	// 目录每页可容纳的条目数
+	pdfTocPerPage = 28
+)
+
+var levelColors = map[string][3]int{
+	common.Critical: {220, 53, 69},
+	common.Alert:    {240, 140, 0},
+	common.Normal:   {200, 160, 0},
+	common.Slight:   {23, 162, 184},
+}
+
+type pdfTocItem struct {
+	Title string
+	Level int
+	Page  int
+	Link  int
+}
+
+type PdfReport struct {
+	BaseReport
+
+	Summary *task.ResultSummary
+
+	pdf *fpdf.Fpdf
+	toc []pdfTocItem
+}
+
+func init() {
+	Register("pdf", func(dir string, summary *task.ResultSummary) Reporter {
+		return &PdfReport{BaseReport: BaseReport{ReportDir: dir}, Summary: summary}
+	})
+}
+
+func (r *PdfReport) contentWidth() float64 {
+	w, _ := r.pdf.GetPageSize()
+	left, _, right, _ := r.pdf.GetMargins()
+	return w - left - right
+}
+
+// ensure 剩余空间不足 h 时换页，返回是否发生了换页
+func (r *PdfReport) ensure(h float64) bool {
+	_, pageHeight := r.pdf.GetPageSize()
+	_, _, _, bottom := r.pdf.GetMargins()
+	if r.pdf.GetY()+h > pageHeight-bottom {
+		r.pdf.AddPage()
+		return true
+	}
+	return false
+}
+
+// wrap 按宽度逐字折行，中文没有空格分词，因此不按单词断行
+func (r *PdfReport) wrap(text string, width float64) []string {
+	var lines []string
+	var current strings.Builder
+	var currentWidth float64
+	for _, c := range text {
+		if c == '\r' {
+			continue
+		}
+		if c == '\n' {
+			lines = append(lines, current.String())
+			current.Reset()
+			currentWidth = 0
+			continue
+		}
+		charWidth := r.pdf.GetStringWidth(string(c))
+		if currentWidth+charWidth > width && current.Len() > 0 {
+			lines = append(lines, current.String())
+			current.Reset()
+			currentWidth = 0
+		}
+		current.WriteRune(c)
+		currentWidth += charWidth
+	}
+	return append(lines, current.String())
+}
+
+func (r *PdfReport) heading(level int, title string) {
+	if level == 1 {
+		r.pdf.AddPage()
+	} else {
+		r.ensure(20)
+	}
+	title = common.T(title)
+	size := map[int]float64{1: 16, 2: 13, 3: 11}[level]
+	link := r.pdf.AddLink()
+	r.pdf.SetLink(link, r.pdf.GetY(), r.pdf.PageNo())
+	r.pdf.Bookmark(title, level-1, -1)
+	if level < 3 {
+		r.toc = append(r.toc, pdfTocItem{Title: title, Level: level, Page: r.pdf.PageNo(), Link: link})
+	}
+	r.pdf.SetFont(pdfFontFamily, "", size)
+	r.pdf.SetTextColor(31, 78, 121)
+	r.pdf.CellFormat(0, size*0.6, title, "", 1, "L", false, 0, "")
+	r.pdf.SetTextColor(0, 0, 0)
+	r.pdf.Ln(2)
+}
+
+func (r *PdfReport) paragraph(text string) {
+	r.pdf.SetFont(pdfFontFamily, "", 10)
+	for _, line := range r.wrap(text, r.contentWidth()) {
+		r.ensure(pdfLineHeight)
+		r.pdf.CellFormat(0, pdfLineHeight, line, "", 1, "L", false, 0, "")
+	}
+	r.pdf.Ln(2)
+}
+
+// table 绘制可自动折行的表格，widths 为各列所占比例，跨页时重复表头
+func (r *PdfReport) table(headers []string, widths []float64, rows [][]string, rowColor func(i int) *[3]int) {
+	if len(rows) == 0 {
+		r.paragraph(common.T("无内容"))
+		return
+	}
+	var total float64
+	for _, w := range widths {
+		total += w
+	}
+	cols := make([]float64, len(widths))
+	for i, w := range widths {
+		cols[i] = w / total * r.contentWidth()
+	}
+	left, _, _, _ := r.pdf.GetMargins()
+	r.pdf.SetFont(pdfFontFamily, "", 9)
+
+	drawRow := func(cells []string, header bool, color *[3]int) {
+		var wrapped [][]string
+		maxLines := 1
+		for i, cell := range cells {
+			lines := r.wrap(common.T(cell), cols[i]-2*pdfCellPad)
+			wrapped = append(wrapped, lines)
+			if len(lines) > maxLines {
+				maxLines = len(lines)
+			}
+		}
+		height := float64(maxLines)*pdfLineHeight + pdfCellPad
+		if r.ensure(height) && !header {
+			r.tableHeader(headers, cols)
+		}
+		x, y := left, r.pdf.GetY()
+		for i, lines := range wrapped {
+			if header {
+				r.pdf.SetFillColor(221, 235, 247)
+				r.pdf.Rect(x, y, cols[i], height, "FD")
+			} else {
+				r.pdf.Rect(x, y, cols[i], height, "D")
+			}
+			if color != nil {
+				r.pdf.SetTextColor(color[0], color[1], color[2])
+			}
+			for j, line := range lines {
+				r.pdf.SetXY(x+pdfCellPad, y+pdfCellPad/2+float64(j)*pdfLineHeight)
+				r.pdf.CellFormat(cols[i]-2*pdfCellPad, pdfLineHeight, line, "", 0, "L", false, 0, "")
+			}
+			r.pdf.SetTextColor(0, 0, 0)
+			x += cols[i]
+		}
+		r.pdf.SetXY(left, y+height)
+	}
+	r.ensure(2 * pdfLineHeight)
+	drawRow(headers, true, nil)
+	for i, row := range rows {
+		var color *[3]int
+		if rowColor != nil {
+			color = rowColor(i)
+		}
+		drawRow(row, false, color)
+	}
+	r.pdf.Ln(4)
+}
+
+func (r *PdfReport) tableHeader(headers []string, cols []float64) {
+	left, _, _, _ := r.pdf.GetMargins()
+	x, y := left, r.pdf.GetY()
+	r.pdf.SetFillColor(221, 235, 247)
+	for i, header := range headers {
+		r.pdf.Rect(x, y, cols[i], pdfLineHeight+pdfCellPad, "FD")
+		r.pdf.SetXY(x+pdfCellPad, y+pdfCellPad/2)
+		r.pdf.CellFormat(cols[i]-2*pdfCellPad, pdfLineHeight, common.T(header), "", 0, "L", false, 0, "")
+		x += cols[i]
+	}
+	r.pdf.SetXY(left, y+pdfLineHeight+pdfCellPad)
+}
+
+func (r *PdfReport) keyValueTable(rows [][]string) {
+	r.table([]string{"项目", "值"}, []float64{1, 2}, rows, nil)
+}
+
+// lineChart 以矢量方式绘制折线图，横轴标签最多显示 6 个
+func (r *PdfReport) lineChart(title string, chart *task.ChartCoordinate) {
+	if chart == nil {
+		return
+	}
+	// 横纵坐标数量不一致时只绘制两者都有的部分
+	count := len(chart.XList)
+	if len(chart.YList) < count {
+		count = len(chart.YList)
+	}
+	if count == 0 {
+		return
+	}
+	values := make([]float64, count)
+	var maxValue float64
+	for i, y := range chart.YList[:count] {
+		values[i], _ = common.ParseCount(y)
+		maxValue = math.Max(maxValue, values[i])
+	}
+	maxValue = niceCeil(maxValue)
+
+	const height, axisWidth = 50.0, 12.0
+	r.ensure(height + 18)
+	r.pdf.SetFont(pdfFontFamily, "", 10)
+	r.pdf.CellFormat(0, 6, common.T(title), "", 1, "C", false, 0, "")
+	left, _, _, _ := r.pdf.GetMargins()
+	x0, y0 := left+axisWidth, r.pdf.GetY()+height
+	width := r.contentWidth() - axisWidth
+
+	r.pdf.SetFont(pdfFontFamily, "", 7)
+	r.pdf.SetDrawColor(220, 220, 220)
+	for i := 0; i <= 4; i++ {
+		y := y0 - height*float64(i)/4
+		r.pdf.Line(x0, y, x0+width, y)
+		r.pdf.SetXY(left, y-2)
+		r.pdf.CellFormat(axisWidth-1, 4, fmt.Sprintf("%g", maxValue*float64(i)/4), "", 0, "R", false, 0, "")
+	}
+	step := width
+	if len(values) > 1 {
+		step = width / float64(len(values)-1)
+	}
+	point := func(i int) (float64, float64) {
+		return x0 + step*float64(i), y0 - height*values[i]/maxValue
+	}
+	r.pdf.SetDrawColor(84, 112, 198)
+	r.pdf.SetLineWidth(0.5)
+	for i := 1; i < len(values); i++ {
+		x1, y1 := point(i - 1)
+		x2, y2 := point(i)
+		r.pdf.Line(x1, y1, x2, y2)
+	}
+	r.pdf.SetLineWidth(0.2)
+	r.pdf.SetDrawColor(0, 0, 0)
+	labelStep := int(math.Ceil(float64(count) / 6))
+	for i := 0; i < count; i += labelStep {
+		x, _ := point(i)
+		r.pdf.SetXY(x-12, y0+1)
+		r.pdf.CellFormat(24, 4, chart.XList[i], "", 0, "C", false, 0, "")
+	}
+	r.pdf.SetXY(left, y0+8)
+}
+
+// barChart 以矢量方式绘制横向柱状图，maxValue 为 0 时取数据中的最大值
+func (r *PdfReport) barChart(title string, labels []string, values []float64, maxValue float64, unit string) {
+	if len(labels) == 0 {
+		return
+	}
+	if maxValue == 0 {
+		for _, v := range values {
+			maxValue = math.Max(maxValue, v)
+		}
+		maxValue = niceCeil(maxValue)
+	}
+	const barHeight, labelWidth, valueWidth = 6.0, 45.0, 20.0
+	r.ensure(barHeight*float64(len(labels)) + 12)
+	r.pdf.SetFont(pdfFontFamily, "", 10)
+	r.pdf.CellFormat(0, 6, common.T(title), "", 1, "C", false, 0, "")
+	left, _, _, _ := r.pdf.GetMargins()
+	width := r.contentWidth() - labelWidth - valueWidth
+	r.pdf.SetFont(pdfFontFamily, "", 8)
+	for i, label := range labels {
+		y := r.pdf.GetY()
+		r.pdf.SetXY(left, y)
+		r.pdf.CellFormat(labelWidth-2, barHeight, label, "", 0, "R", false, 0, "")
+		r.pdf.SetFillColor(84, 112, 198)
+		if unit == "%" && values[i] >= 80 {
+			r.pdf.SetFillColor(220, 53, 69)
+		}
+		r.pdf.Rect(left+labelWidth, y+1, width*values[i]/maxValue, barHeight-2, "F")
+		r.pdf.SetXY(left+labelWidth+width*values[i]/maxValue+1, y)
+		r.pdf.CellFormat(valueWidth, barHeight, fmt.Sprintf("%g%s", values[i], unit), "", 0, "L", false, 0, "")
+		r.pdf.SetXY(left, y+barHeight)
+	}
+	r.pdf.Ln(4)
+}
+
+// niceCeil 将坐标轴最大值向上取整到 1、2、5 的倍数
+func niceCeil(value float64) float64 {
+	if value <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, n := range []float64{1, 2, 5, 10} {
+		if value <= n*magnitude {
+			return n * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func (r *PdfReport) writeCover() {
+	g := r.Summary.GlobalInfo
+	r.pdf.AddPage()
+	r.pdf.SetY(80)
+	r.pdf.SetFont(pdfFontFamily, "", 28)
+	r.pdf.SetTextColor(31, 78, 121)
+	r.pdf.CellFormat(0, 16, common.T("JumpServer 巡检报告"), "", 1, "C", false, 0, "")
+	r.pdf.SetTextColor(0, 0, 0)
+	r.pdf.Ln(20)
+
+	counts := make(map[string]int)
+	for _, msg := range r.Summary.AbnormalResults {
+		counts[msg.Level]++
+	}
+	r.pdf.SetFont(pdfFontFamily, "", 12)
+	lines := [][]string{
+		{"巡检时间", g.InspectDatetime},
+		{"JumpServer 版本", g.JMSVersion},
+		{"巡检节点", common.Tf("共 %d 个（应用 %d，数据库 %d，Redis %d）", g.TotalCount, g.JMSCount, g.RDSCount, g.RedisCount)},
+		{"异常项", common.Tf(
+			"严重 %d，警告 %d，一般 %d，轻微 %d",
+			counts[common.Critical], counts[common.Alert], counts[common.Normal], counts[common.Slight],
+		)},
+	}
+	for _, line := range lines {
+		r.pdf.SetX(45)
+		r.pdf.CellFormat(40, 10, common.T(line[0]), "", 0, "L", false, 0, "")
+		r.pdf.CellFormat(0, 10, line[1], "", 1, "L", false, 0, "")
+	}
+
+	r.pdf.SetY(230)
+	for _, name := range []string{"巡检人", "确认人", "日期"} {
+		r.pdf.SetX(45)
+		r.pdf.CellFormat(30, 10, common.T(name)+":", "", 0, "L", false, 0, "")
+		x, y := r.pdf.GetXY()
+		r.pdf.Line(x, y+8, x+70, y+8)
+		r.pdf.Ln(12)
+	}
+}
+
+// writeToc 回到预留的目录页补写目录，需在正文全部生成后调用
+func (r *PdfReport) writeToc(firstPage, pageCount int) {
+	left, top, _, _ := r.pdf.GetMargins()
+	width := r.contentWidth()
+	for i, item := range r.toc {
+		if i%pdfTocPerPage == 0 {
+			page := firstPage + i/pdfTocPerPage
+			if page >= firstPage+pageCount {
+				break
+			}
+			r.pdf.SetPage(page)
+			r.pdf.SetXY(left, top)
+			if i == 0 {
+				r.pdf.SetFont(pdfFontFamily, "", 18)
+				r.pdf.CellFormat(0, 12, common.T("目录"), "", 1, "C", false, 0, "")
+				r.pdf.Ln(4)
+			}
+		}
+		indent := float64(item.Level-1) * 8
+		size := map[int]float64{1: 11, 2: 10}[item.Level]
+		r.pdf.SetFont(pdfFontFamily, "", size)
+		x, y := left+indent, r.pdf.GetY()
+		r.pdf.SetXY(x, y)
+		r.pdf.CellFormat(width-indent-15, 8, item.Title, "B", 0, "L", false, 0, "")
+		r.pdf.CellFormat(15, 8, fmt.Sprint(item.Page), "B", 1, "R", false, 0, "")
+		r.pdf.Link(x, y, width-indent, 8, item.Link)
+	}
+}
+
+func (r *PdfReport) writeMachine(index int, m *task.MachineResult) {
+	r.heading(2, fmt.Sprintf("3.%d %s", index+1, m.MachineName))
+	r.heading(3, "系统信息")
+	r.keyValueTable(osInfoRows(m))
+	r.heading(3, "磁盘信息")
+	r.table(diskHeaders, []float64{3, 1.2, 1, 1, 1, 1, 2}, diskRows(m), nil)
+	var labels []string
+	var values []float64
+	for _, d := range m.DiskInfoList {
+		if usage, ok := common.ParsePercent(d.FileUsageRate); ok {
+			labels = append(labels, d.FileMount)
+			values = append(values, usage)
+		}
+	}
+	r.barChart("磁盘使用率", labels, values, 100, "%")
+	if m.ServiceResult == nil {
+		return
+	}
+	r.heading(3, "JumpServer服务占用磁盘情况")
+	r.keyValueTable(serviceRows(m.ServiceResult))
+	r.heading(3, "JumpServer服务组件状态")
+	r.table(componentHeaders, []float64{2, 1, 1, 2}, componentRows(m.ServiceResult), nil)
+	if rows := containerRows(m.ServiceResult); len(rows) > 0 {
+		r.heading(3, "JumpServer容器详情")
+		r.table(containerHeaders, []float64{2, 1.2, 1.2, 1.2, 1, 3, 1, 2.4, 3}, rows, nil)
+	}
+	if rows := logErrorRows(m.ServiceResult); len(rows) > 0 {
+		r.heading(3, "JumpServer组件日志错误")
+		r.table(logErrorHeaders, []float64{1.2, 0.8, 2, 3, 4}, rows, nil)
+	}
+}
+
+func (r *PdfReport) writeSummary(v *task.SummaryResult) {
+	r.heading(1, "5. 运营巡检")
+	r.paragraph(summaryOverview(v))
+	r.keyValueTable(summaryRows(v))
+	r.lineChart("近三月用户登录次数", v.UserLoginChart)
+	r.lineChart("近三月资产登录次数", v.AssetLoginChart)
+	r.lineChart("近三月活跃用户数", v.ActiveUserChart)
+	r.lineChart("近三月活跃资产数", v.ActiveAssetChart)
+
+	var items []task.PieItem
+	_ = json.Unmarshal([]byte(v.ProtocolChart), &items)
+	var labels []string
+	var values []float64
+	for _, item := range items {
+		value, _ := common.ParseCount(item.Value)
+		labels = append(labels, item.Name)
+		values = append(values, value)
+	}
+	r.barChart("近三月各协议访问次数", labels, values, 0, "")
+}
+
+func (r *PdfReport) Generate() ([]string, error) {
+	if len(pdfFontData) == 0 {
+		return nil, errors.New(common.T("缺少 PDF 报告所需的中文字体文件，请使用 build.sh 编译"))
+	}
+	// 目录需预留在正文之前，先按章节上限排版，实际目录页数不同时再重新排版一次
+	tocPages := pdfTocPages(13 + len(r.Summary.NormalResults))
+	r.layout(tocPages)
+	if actual := pdfTocPages(len(r.toc)); actual != tocPages {
+		r.layout(actual)
+	}
+	if err := r.pdf.Error(); err != nil {
+		return nil, err
+	}
+
+	outputFile, err := r.GetReportFile("pdf")
+	if err != nil {
+		return nil, err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+	if err = r.pdf.Output(outputFile); err != nil {
+		return nil, err
+	}
+	return []string{r.ReportPath}, nil
+}
+
+func pdfTocPages(items int) int {
+	if items < 1 {
+		items = 1
+	}
+	return int(math.Ceil(float64(items) / pdfTocPerPage))
+}
+
+// layout 生成封面、预留的目录页及正文，正文完成后补写目录
+func (r *PdfReport) layout(tocPages int) {
+	r.toc = nil
+	r.pdf = fpdf.New("P", "mm", "A4", "")
+	r.pdf.SetMargins(15, 18, 15)
+	r.pdf.SetAutoPageBreak(false, 18)
+	r.pdf.AddUTF8FontFromBytes(pdfFontFamily, "", pdfFontData)
+	r.pdf.SetTitle(common.T("JumpServer 巡检报告"), true)
+	r.pdf.SetFooterFunc(func() {
+		if r.pdf.PageNo() == 1 {
+			return
+		}
+		r.pdf.SetY(-12)
+		r.pdf.SetFont(pdfFontFamily, "", 9)
+		r.pdf.SetTextColor(128, 128, 128)
+		r.pdf.CellFormat(0, 6, common.Tf("第 %d 页", r.pdf.PageNo()), "", 0, "C", false, 0, "")
+		r.pdf.SetTextColor(0, 0, 0)
+	})
+
+	r.writeCover()
+	tocFirstPage := r.pdf.PageNo() + 1
+	for i := 0; i < tocPages; i++ {
+		r.pdf.AddPage()
+	}
+
+	g := r.Summary.GlobalInfo
+	r.heading(1, "1. 巡检概述")
+	r.paragraph(common.Tf(
+		"本次巡检共巡检 %d 个节点，其中应用节点 %d 个，数据库节点 %d 个，Redis节点 %d 个。",
+		g.TotalCount, g.JMSCount, g.RDSCount, g.RedisCount,
+	))
+	r.table(globalHeaders, []float64{2, 1.2, 1.6, 1, 1.2, 1}, globalRows(g), nil)
+
+	r.heading(1, "2. 异常汇总")
+	abnormal := sortedAbnormal(r.Summary.AbnormalResults)
+	var abnormalRows [][]string
+	for _, msg := range abnormal {
+		abnormalRows = append(abnormalRows, []string{msg.LevelDisplay, msg.NodeName, msg.Desc})
+	}
+	r.table([]string{"异常等级", "异常节点", "异常描述"}, []float64{1, 2, 5}, abnormalRows, func(i int) *[3]int {
+		if color, ok := levelColors[abnormal[i].Level]; ok {
+			return &color
+		}
+		return nil
+	})
+
+	r.heading(1, "3. 机器详情")
+	for i := range r.Summary.NormalResults {
+		r.writeMachine(i, &r.Summary.NormalResults[i])
+	}
+
+	db := &r.Summary.DBResult
+	if db.HasRDSInfo || db.HasRedisInfo {
+		r.heading(1, "4. 数据库")
+		if db.HasRDSInfo {
+			r.heading(2, "4.1 RDS 状态")
+			r.keyValueTable(rdsRows(db))
+			r.heading(2, "4.2 数据库表大小前10位")
+			r.table(tableHeaders, []float64{3, 1, 1}, tableRows(db), nil)
+		}
+		if db.HasRedisInfo {
+			r.heading(2, "4.3 Redis 状态")
+			r.keyValueTable(redisRows(&db.RedisResult))
+		}
+	}
+	if r.Summary.VirtualResult != nil {
+		r.writeSummary(r.Summary.VirtualResult)
+	}
+	if len(r.Summary.TLSResult) > 0 {
+		r.heading(1, "6. TLS 证书")
+		r.table(tlsHeaders, []float64{1.6, 1.6, 1.6, 1.6, 1, 1, 1}, tlsRows(r.Summary.TLSResult), nil)
+	}
+	if c := r.Summary.CeleryResult; c != nil {
+		r.heading(1, "7. Celery 任务")
+		r.heading(2, "7.1 Celery 运行状态")
+		r.table(celeryHeaders, []float64{1, 2, 1, 1}, celeryRows(c), nil)
+		r.heading(2, "7.2 周期任务")
+		if c.PeriodicError != "" {
+			r.paragraph(common.Tf("查询失败: %s", c.PeriodicError))
+		} else {
+			r.table(periodicHeaders, []float64{2.4, 1.2, 1.4, 1.4, 1, 1}, periodicRows(c), nil)
+		}
+	}
+	if len(r.Summary.StorageResult) > 0 {
+		r.heading(1, "8. 录像及命令存储")
+		r.table(storageHeaders, []float64{1, 1.4, 0.8, 0.6, 2.2, 1.2, 2}, storageRows(r.Summary.StorageResult), nil)
+	}
+	// 最后一页的页脚在输出时才会补上，需在补写目录前完成
+	lastPage := r.pdf.PageNo()
+	r.writeToc(tocFirstPage, tocPages)
+	r.pdf.SetPage(lastPage)
+}
@@ -0,0 +1,159 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+const (
+	PromMetricPrefix = "jms_inspect_"
+	// PromTextfileDirEnv node_exporter textfile collector 目录，设置后会额外写入固定文件名的指标文件
+	PromTextfileDirEnv = "JMS_INSPECT_TEXTFILE_DIR"
+	PromTextfileName   = "jms_inspect.prom"
+)
+
+// 指标名按 Prometheus 规范追加单位后缀
+var promUnitSuffix = map[string]string{
+	task.UnitBytes:   "_bytes",
+	task.UnitPercent: "_percent",
+	task.UnitSeconds: "_seconds",
+}
+
+var promInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+type PrometheusReport struct {
+	BaseReport
+
+	Summary *task.ResultSummary
+}
+
+func init() {
+	Register("prom", func(dir string, summary *task.ResultSummary) Reporter {
+		return &PrometheusReport{BaseReport: BaseReport{ReportDir: dir}, Summary: summary}
+	})
+}
+
+func promMetricName(metric task.Metric) string {
+	name := promInvalidChars.ReplaceAllString(metric.Name, "_")
+	return PromMetricPrefix + name + promUnitSuffix[metric.Unit]
+}
+
+func promEscape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func promLabels(metric task.Metric) string {
+	labels := make(map[string]string)
+	for key, value := range metric.Labels {
+		labels[promInvalidChars.ReplaceAllString(key, "_")] = value
+	}
+	if metric.Node != "" {
+		labels["machine"] = metric.Node
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, key, promEscape(labels[key])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// PrometheusText 按 Prometheus 文本格式输出指标，同名指标的样本需要连续输出
+func PrometheusText(metrics []task.Metric) string {
+	var names []string
+	families := make(map[string][]task.Metric)
+	for _, metric := range metrics {
+		name := promMetricName(metric)
+		if _, exist := families[name]; !exist {
+			names = append(names, name)
+		}
+		families[name] = append(families[name], metric)
+	}
+	var b strings.Builder
+	for _, name := range names {
+		samples := families[name]
+		help := strings.ReplaceAll(task.MetricDescription(samples[0].Name), "\n", " ")
+		b.WriteString(fmt.Sprintf("# HELP %s %s\n", name, help))
+		b.WriteString(fmt.Sprintf("# TYPE %s gauge\n", name))
+		for _, metric := range samples {
+			value := strconv.FormatFloat(metric.Value, 'g', -1, 64)
+			b.WriteString(name + promLabels(metric) + " " + value + "\n")
+		}
+	}
+	return b.String()
+}
+
+func (r *PrometheusReport) text() string {
+	metrics := r.Summary.Metrics()
+	if !r.Summary.GlobalInfo.InspectTime.IsZero() {
+		metrics = append(metrics, task.Metric{
+			Name: "last_run_timestamp", Unit: task.UnitSeconds,
+			Value: float64(r.Summary.GlobalInfo.InspectTime.Unix()),
+		})
+	}
+	return PrometheusText(metrics)
+}
+
+// writeTextfile 先写临时文件再重命名，避免 node_exporter 读到写了一半的文件
+func writeTextfile(dir, content string) (string, error) {
+	path := filepath.Join(dir, PromTextfileName)
+	tmpFile, err := os.CreateTemp(dir, PromTextfileName+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func(name string) {
+		_ = os.Remove(name)
+	}(tmpFile.Name())
+	if _, err = tmpFile.WriteString(content); err != nil {
+		_ = tmpFile.Close()
+		return "", err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmpFile.Name(), path)
+}
+
+func (r *PrometheusReport) Generate() ([]string, error) {
+	outputFile, err := r.GetReportFile("prom")
+	if err != nil {
+		return nil, err
+	}
+	defer func(outputFile *os.File) {
+		_ = outputFile.Close()
+	}(outputFile)
+
+	content := r.text()
+	if _, err = outputFile.WriteString(content); err != nil {
+		return nil, err
+	}
+	paths := []string{r.ReportPath}
+	if dir := os.Getenv(PromTextfileDirEnv); dir != "" {
+		path, err := writeTextfile(dir, content)
+		if err != nil {
+			return paths, fmt.Errorf(common.T("写入 textfile 目录 %s 失败: %w"), dir, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,164 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tealeg/xlsx"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+const (
+	testMachinePassword = "Jms@Passw0rd!2024"
+	testBootstrapToken  = "Qv8Xn2LmT5cRw9Zk"
+	testShortPassword   = "Pa55w"
+)
+
+func init() {
+	common.RegisterSecret(testMachinePassword, testShortPassword, "root", "admin", "jump")
+	common.RegisterConfigSecrets(map[string]string{"BOOTSTRAP_TOKEN": testBootstrapToken})
+}
+
+func redactTestSummary() *task.ResultSummary {
+	now := time.Now()
+	return &task.ResultSummary{
+		GlobalInfo: task.GlobalInfo{
+			Machines: []task.Machine{
+				{Name: "jump", Type: "jumpserver", Host: "10.0.0.1", Port: "22", Username: "root", Password: testMachinePassword, Valid: true},
+			},
+			JMSCount: 1, TotalCount: 1,
+			InspectDatetime: now.Format("2006-01-02 15:04:05"), InspectTime: now, JMSVersion: "v3.10.0",
+		},
+		AbnormalResults: []task.AbnormalMsg{
+			{Level: common.Critical, LevelDisplay: "严重", NodeName: "jump", Desc: "配置 BOOTSTRAP_TOKEN 为 " + testBootstrapToken},
+		},
+		NormalResults: []task.MachineResult{
+			{
+				MachineType: "jumpserver", MachineName: "jump",
+				OsInfoResult: task.OsInfoResult{
+					MachineHostname: "admin",
+					DiskInfoList: []task.DiskInfo{
+						{FileSystem: "/dev/sda", FileSize: "50G", FileUsed: "20G", FileUsageRate: "40%", FileMount: "/root"},
+					},
+				},
+				ServiceResult: &task.ServiceResult{
+					ReplayPath: "/data/admin/replays",
+					ComponentInfo: []task.Component{
+						{
+							ServiceName: "jms_core", ServiceStatus: "Up 3 hours", ContainerState: "running",
+							Image:  "jumpserver/jump:v3.10.0",
+							Mounts: []string{"/opt/jumpserver/config/config.txt:/opt/jumpserver/config.txt:ro"},
+						},
+					},
+				},
+			},
+		},
+		TaskRecords: []task.TaskRecord{
+			{Name: "数据库", Error: "dial mysql://root:" + testMachinePassword + "@10.0.0.1:3306 refused"},
+			{Name: "机器当前系统检查", NodeName: "jump", Error: "sudo -p" + testShortPassword + " failed: '" + testShortPassword + "' rejected"},
+		},
+		StorageResult: []task.StorageInfo{
+			{Kind: task.StorageKindCommand, Name: "es", Type: "es", Endpoint: "http://elastic:" + testMachinePassword + "@10.0.0.1:9200"},
+		},
+	}
+}
+
+// 报告中不能出现登记的密码，而与较短密码相同的用户名、路径、镜像名等正常内容需保留
+var (
+	redactSecrets = []string{testMachinePassword, testBootstrapToken, testShortPassword}
+	redactKeeps   = []string{"root", "admin", "/data/admin/replays", "jumpserver/jump:v3.10.0"}
+)
+
+func checkRedacted(t *testing.T, name, content string) {
+	t.Helper()
+	for _, secret := range redactSecrets {
+		if strings.Contains(content, secret) {
+			t.Errorf("%s 中包含敏感信息 %s", name, secret)
+		}
+	}
+	for _, value := range redactKeeps {
+		if !strings.Contains(content, value) {
+			t.Errorf("%s 中缺少正常内容 %s", name, value)
+		}
+	}
+}
+
+func TestRedactSummary(t *testing.T) {
+	summary := redactTestSummary()
+	redacted, err := redactSummary(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"用户名", redacted.GlobalInfo.Machines[0].Username, "root"},
+		{"主机名", redacted.NormalResults[0].MachineHostname, "admin"},
+		{"挂载点", redacted.NormalResults[0].DiskInfoList[0].FileMount, "/root"},
+		{"镜像", redacted.NormalResults[0].ComponentInfo[0].Image, "jumpserver/jump:v3.10.0"},
+		{"异常描述", redacted.AbnormalResults[0].Desc, "配置 BOOTSTRAP_TOKEN 为 " + common.RedactedMask},
+		{"任务错误", redacted.TaskRecords[0].Error, "dial mysql://root:" + common.RedactedMask + "@10.0.0.1:3306 refused"},
+		{"存储地址", redacted.StorageResult[0].Endpoint, "http://elastic:" + common.RedactedMask + "@10.0.0.1:9200"},
+		{"短密码", redacted.TaskRecords[1].Error, "sudo -p****** failed: '******' rejected"},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	// 原始结果不能被修改
+	if summary.AbnormalResults[0].Desc != "配置 BOOTSTRAP_TOKEN 为 "+testBootstrapToken {
+		t.Errorf("原始巡检结果被修改: %q", summary.AbnormalResults[0].Desc)
+	}
+}
+
+func TestGenerateRedacted(t *testing.T) {
+	readText := func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+	readExcel := func(path string) (string, error) {
+		file, err := xlsx.OpenFile(path)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		for _, sheet := range file.Sheets {
+			for _, row := range sheet.Rows {
+				for _, cell := range row.Cells {
+					b.WriteString(cell.String() + "\n")
+				}
+			}
+		}
+		return b.String(), nil
+	}
+	cases := []struct {
+		format string
+		read   func(path string) (string, error)
+	}{
+		{"json", readText},
+		{"xlsx", readExcel},
+		{"html", readText},
+	}
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			paths, err := Generate(t.TempDir(), []string{c.format}, redactTestSummary())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, path := range paths {
+				content, err := c.read(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkRedacted(t, path, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+// Reporter 各格式报告生成器，返回本次生成的全部文件路径
+type Reporter interface {
+	Generate() ([]string, error)
+}
+
+// Factory 创建报告生成器，dir 为报告输出目录
+type Factory func(dir string, summary *task.ResultSummary) Reporter
+
+var registry = make(map[string]Factory)
+
+// Register 注册报告格式，各报告在 init 中调用
+func Register(format string, factory Factory) {
+	if _, exist := registry[format]; exist {
+		panic(fmt.Sprintf("报告格式 %s 重复注册", format))
+	}
+	registry[format] = factory
+}
+
+func Formats() []string {
+	var formats []string
+	for format := range registry {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ParseFormats 解析逗号分隔的格式列表，忽略空项和重复项
+func ParseFormats(value string) ([]string, error) {
+	var formats []string
+	exist := make(map[string]bool)
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" || exist[format] {
+			continue
+		}
+		if _, ok := registry[format]; !ok {
+			return nil, fmt.Errorf(
+				common.T("不支持的报告格式: %s，可选格式: %s"), format, strings.Join(Formats(), "、"),
+			)
+		}
+		exist[format] = true
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return nil, errors.New(common.T("请至少指定一种报告格式"))
+	}
+	return formats, nil
+}
+
+// NewReporter 报告生成器拿到的是脱敏后的巡检结果副本
+func NewReporter(format, dir string, summary *task.ResultSummary) (Reporter, error) {
+	factory, ok := registry[format]
+	if !ok {
+		return nil, fmt.Errorf(common.T("不支持的报告格式: %s"), format)
+	}
+	redacted, err := redactSummary(summary)
+	if err != nil {
+		return nil, fmt.Errorf(common.T("巡检结果脱敏失败: %w"), err)
+	}
+	return factory(dir, redacted), nil
+}
+
+func redactSummary(summary *task.ResultSummary) (*task.ResultSummary, error) {
+	redacted := &task.ResultSummary{}
+	if err := common.RedactInto(summary, redacted); err != nil {
+		return nil, err
+	}
+	// 不参与序列化的字段不含巡检数据，直接复制
+	redacted.EchartsData, redacted.TrendData = summary.EchartsData, summary.TrendData
+	return redacted, nil
+}
+
+// Generate 按顺序在 dir 下生成指定格式的报告，遇到错误立即返回已生成的文件
+func Generate(dir string, formats []string, summary *task.ResultSummary) ([]string, error) {
+	var paths []string
+	for _, format := range formats {
+		reporter, err := NewReporter(format, dir, summary)
+		if err != nil {
+			return paths, err
+		}
+		files, err := reporter.Generate()
+		if err != nil {
+			return paths, fmt.Errorf(common.T("生成 %s 格式报告错误: %w"), format, err)
+		}
+		paths = append(paths, files...)
+	}
+	return paths, nil
+}
@@ -0,0 +1,211 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"inspect/pkg/common"
+	"inspect/pkg/task"
+)
+
+// 以下为 Markdown、PDF 等非模板类报告共用的表格数据
+
+var (
+	globalHeaders    = []string{"机器名", "机器类型", "机器IP", "机器端口", "SSH用户名", "是否有效"}
+	diskHeaders      = []string{"文件系统", "类型", "大小", "已使用", "剩余可用", "使用率", "挂载点"}
+	componentHeaders = []string{"组件名称", "状态", "端口", "健康检查"}
+	containerHeaders = []string{"组件名称", "运行状态", "健康状态", "重启次数", "OOM", "镜像", "CPU", "内存", "挂载卷"}
+	logErrorHeaders  = []string{"组件名称", "次数", "出现时间", "错误特征", "日志摘录"}
+	tableHeaders     = []string{"表名", "记录数", "表大小"}
+	tlsHeaders       = []string{"地址", "主题", "SAN", "颁发者", "证书链", "协议版本", "剩余有效期"}
+	celeryHeaders    = []string{"机器名", "容器", "worker 进程数", "beat 进程"}
+	periodicHeaders  = []string{"任务名称", "执行周期", "最近调度时间", "最近成功时间", "最近状态", "未按时执行"}
+	storageHeaders   = []string{"用途", "名称", "类型", "默认", "地址", "存储桶", "状态"}
+)
+
+func globalRows(g task.GlobalInfo) [][]string {
+	var rows [][]string
+	for _, m := range g.Machines {
+		rows = append(rows, []string{m.Name, m.Type, m.Host, m.Port, m.Username, common.BoolDisplay(m.Valid)})
+	}
+	return rows
+}
+
+// sortedAbnormal 按异常等级从高到低排序，不修改原切片
+func sortedAbnormal(results []task.AbnormalMsg) []task.AbnormalMsg {
+	msgs := make([]task.AbnormalMsg, len(results))
+	copy(msgs, results)
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return task.LevelPriority(msgs[i].Level) > task.LevelPriority(msgs[j].Level)
+	})
+	return msgs
+}
+
+func osInfoRows(m *task.MachineResult) [][]string {
+	return [][]string{
+		{"主机名", m.MachineHostname}, {"语言", m.MachineLanguage}, {"地址", m.MachineAddress},
+		{"系统版本", m.OsVersion}, {"内核版本", m.KernelVersion}, {"CPU架构", m.CpuArch},
+		{"当前时间", m.CurrentTime}, {"最后启动时间", m.LastUpTime}, {"运行天数", m.OperatingTime},
+		{"物理CPU个数", m.CpuNum}, {"每物理CPU核数", m.CpuPhysicalCores},
+		{"逻辑CPU核数", m.CpuLogicalCores}, {"CPU型号", m.CpuModel},
+		{"内存总量", m.MemoryTotal}, {"内存已使用量", m.MemoryUsed}, {"内存空闲", m.MemoryAvailable},
+		{"SWAP总量", m.SwapTotal}, {"SWAP已使用", m.SwapUsed}, {"SWAP空闲", m.SwapFree},
+		{"SELinux是否开启", m.SelinuxEnable}, {"防火墙是否开启", m.FirewallEnable},
+		{"是否配置定时任务", m.CrontabEnable}, {"是否开启RSyslog", m.RsyslogEnable},
+		{"是否存在僵尸进程", m.ExistZombie}, {"系统开放端口", m.ExposePort},
+	}
+}
+
+func diskRows(m *task.MachineResult) [][]string {
+	var rows [][]string
+	for _, d := range m.DiskInfoList {
+		rows = append(rows, []string{
+			d.FileSystem, d.FileType, d.FileSize, d.FileUsed, d.FileAvailable, d.FileUsageRate, d.FileMount,
+		})
+	}
+	return rows
+}
+
+func serviceRows(s *task.ServiceResult) [][]string {
+	rows := [][]string{
+		{"录像存储路径", s.ReplayPath}, {"录像空间总大小", s.ReplayTotal},
+		{"录像存储已使用", s.ReplayUsed}, {"录像存储空闲", s.ReplayUnused},
+	}
+	for _, c := range s.ComponentLogSize {
+		rows = append(rows, []string{c.ServiceName, c.ServiceLogSize})
+	}
+	return rows
+}
+
+func componentRows(s *task.ServiceResult) [][]string {
+	var rows [][]string
+	for _, c := range s.ComponentInfo {
+		rows = append(rows, []string{c.ServiceName, c.ServiceStatus, c.ServicePort, c.HealthDisplay()})
+	}
+	return rows
+}
+
+// containerRows 只包含通过 docker inspect 获取到详情的容器
+func containerRows(s *task.ServiceResult) [][]string {
+	var rows [][]string
+	for _, c := range s.ComponentInfo {
+		if c.ContainerState == "" {
+			continue
+		}
+		rows = append(rows, []string{
+			c.ServiceName, c.ContainerState, c.ContainerHealthDisplay(), strconv.Itoa(c.RestartCount),
+			common.BoolDisplay(c.OOMKilled), c.ImageDisplay(), c.CPUDisplay(), c.MemoryDisplay(), c.MountDisplay(),
+		})
+	}
+	return rows
+}
+
+func logErrorRows(s *task.ServiceResult) [][]string {
+	var rows [][]string
+	for _, c := range s.LogErrors {
+		for _, sig := range c.Signatures {
+			rows = append(rows, []string{
+				c.Component, strconv.Itoa(sig.Count), sig.SeenDisplay(), sig.Signature, sig.Evidence,
+			})
+		}
+	}
+	return rows
+}
+
+func tlsRows(certs []task.TLSCertInfo) [][]string {
+	var rows [][]string
+	for _, c := range certs {
+		rows = append(rows, []string{
+			c.AddressDisplay(), c.SubjectDisplay(), c.SANDisplay(), c.Issuer,
+			c.ChainDisplay(), c.VersionDisplay(), c.ExpiryDisplay(),
+		})
+	}
+	return rows
+}
+
+func celeryRows(c *task.CeleryResult) [][]string {
+	var rows [][]string
+	for _, w := range c.Workers {
+		rows = append(rows, []string{
+			w.NodeName, w.ContainerDisplay(), strconv.Itoa(w.WorkerProcesses), common.BoolDisplay(w.BeatRunning),
+		})
+	}
+	return rows
+}
+
+func periodicRows(c *task.CeleryResult) [][]string {
+	var rows [][]string
+	for _, p := range c.PeriodicTasks {
+		rows = append(rows, []string{
+			p.Name, p.Schedule, p.LastRunDisplay(), p.LastSuccessDisplay(), p.LastStateDisplay(), common.BoolDisplay(p.Overdue),
+		})
+	}
+	return rows
+}
+
+func storageRows(storages []task.StorageInfo) [][]string {
+	var rows [][]string
+	for _, s := range storages {
+		rows = append(rows, []string{
+			s.KindDisplay(), s.Name, s.Type, common.BoolDisplay(s.IsDefault), s.Endpoint, s.Bucket, s.StatusDisplay(),
+		})
+	}
+	return rows
+}
+
+func rdsRows(db *task.DBResult) [][]string {
+	var rows [][]string
+	for _, info := range db.DBInfo {
+		rows = append(rows, []string{info.Name, fmt.Sprint(info.Value)})
+	}
+	return append(rows, []string{"数据库大小", db.DBSize})
+}
+
+func tableRows(db *task.DBResult) [][]string {
+	var rows [][]string
+	for _, t := range db.Top10Table {
+		rows = append(rows, []string{t.TableName, t.TableRecord, t.TableSize})
+	}
+	return rows
+}
+
+func redisRows(redis *task.RedisResult) [][]string {
+	return [][]string{
+		{"Redis版本", redis.RedisVersion}, {"Redis模型", redis.RedisMode},
+		{"TCP Port", redis.RedisPort}, {"运行时间(天)", redis.RedisUptime},
+		{"当前连接数", redis.RedisConnect}, {"集群连接数", redis.RedisClusterConnect},
+		{"最大连接数", redis.RedisMaxConnect}, {"当前阻塞连接", redis.RedisBlockedConnect},
+		{"已使用内存", redis.UsedMemoryHuman}, {"物理内存占用量", redis.UsedMemoryRssHuman},
+		{"内存使用最大值", redis.UsedMemoryPeakHuman}, {"Lua使用内存量", redis.UsedMemoryLuaHuman},
+		{"最大可用内存", redis.MaxMemoryHuman}, {"淘汰策略", redis.MaxMemoryPolicy},
+		{"共接受的连接数", redis.TotalConnectionsReceived}, {"共处理的命令数", redis.TotalCommandsProcessed},
+		{"每秒执行的命令数", redis.InstantaneousOpsPerSec}, {"入口流量(字节)", redis.TotalNetInputBytes},
+		{"出口流量(字节)", redis.TotalNetOutputBytes}, {"被拒绝连接数", redis.RejectedConnections},
+		{"过期键个数", redis.ExpiredKeys}, {"执行淘汰策略键个数", redis.EvictedKeys},
+		{"键命中次数", redis.KeyspaceHits}, {"键未命中次数", redis.KeyspaceMisses},
+		{"发布订阅频道数", redis.PubSubChannels}, {"发布订阅匹配模式频道数", redis.PubSubPatterns},
+	}
+}
+
+func summaryOverview(v *task.SummaryResult) string {
+	return common.Tf(
+		"当前共有 %s 个组织，%s 个用户，%s 个资产。资产类型前三: %s。%s 个在线会话。",
+		v.OrganizationCount, v.UserCount, v.AssetCount, v.AssetCountDisplay, v.OnlineSession,
+	)
+}
+
+func summaryRows(v *task.SummaryResult) [][]string {
+	return [][]string{
+		{"最大单日登录次数", v.MaxLoginCount}, {"最大单日访问资产数", v.MaxLoginAssetCount},
+		{"近三月最大单日用户登录数", v.Last3MonthMaxLoginCount},
+		{"近三月最大单日资产登录数", v.Last3MonthMaxLoginAssetCount},
+		{"近三月登录用户数", v.Last3MonthLoginCount}, {"近三月登录资产数", v.Last3MonthConnectAssetCount},
+		{"近三月文件上传数", v.Last3MonthUploadCount}, {"近一月登录用户数", v.Last1MonthLoginCount},
+		{"近一月登录资产数", v.Last1MonthConnectAssetCount}, {"近一月文件上传次数", v.Last1MonthUploadCount},
+		{"近三月命令记录数", v.Last3MonthCommandCount}, {"近三月高危命令记录数", v.Last3MonthDangerCommandCount},
+		{"近三月最大会话时长", v.Last3MonthMaxSessionDuration},
+		{"近三月平均会话时长", v.Last3MonthAvgSessionDuration},
+		{"近三月工单申请数", v.Last3MonthTicketCount},
+	}
+}
@@ -24,7 +24,12 @@ type Machine struct {
 	SSHKeyPassphrase string `yaml:"ssh_key_passphrase" json:"-"`
 	PriType          string `yaml:"privilege_type" json:"privilege_type"`
 	PriPwd           string `yaml:"privilege_password" json:"-"`
-	Valid            bool   `yaml:"-" json:"valid"`
+	// 未直接配置密码时，从指定的环境变量或文件中读取
+	PasswordEnv  string `yaml:"password_env,omitempty" json:"-"`
+	PasswordFile string `yaml:"password_file,omitempty" json:"-"`
+	PriPwdEnv    string `yaml:"privilege_password_env,omitempty" json:"-"`
+	PriPwdFile   string `yaml:"privilege_password_file,omitempty" json:"-"`
+	Valid        bool   `yaml:"-" json:"valid"`
 
 	Client *ssh.Client `yaml:"-" json:"-"`
 }
@@ -41,7 +46,7 @@ func (m *Machine) isValidType(machineType string) error {
 			validNames = append(validNames, t)
 		}
 		sort.Strings(validNames)
-		return fmt.Errorf("无效的类型 %s, 目前仅支持 %s", machineType, strings.Join(validNames, ", "))
+		return fmt.Errorf(common.T("无效的类型 %s, 目前仅支持 %s"), machineType, strings.Join(validNames, ", "))
 	}
 	return nil
 }
@@ -62,7 +67,7 @@ func (m *Machine) isValidPriType(priType string) error {
 			validNames = append(validNames, t)
 		}
 		sort.Strings(validNames)
-		return fmt.Errorf("无效的类型 %s, 目前仅支持 %s", priType, strings.Join(validNames, ", "))
+		return fmt.Errorf(common.T("无效的类型 %s, 目前仅支持 %s"), priType, strings.Join(validNames, ", "))
 	}
 	return nil
 }
@@ -86,18 +91,18 @@ func (m *Machine) Connect() error {
 	if m.SSHKeyPath != "" {
 		key, err := os.ReadFile(m.SSHKeyPath)
 		if err != nil {
-			return fmt.Errorf("密钥文件读取失败: %w", err)
+			return fmt.Errorf(common.T("密钥文件读取失败: %w"), err)
 		}
 		var signer ssh.Signer
 		if m.SSHKeyPassphrase != "" {
 			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(m.SSHKeyPassphrase))
 			if err != nil {
-				return fmt.Errorf("带密码的密钥解析失败（密码可能错误）: %w", err)
+				return fmt.Errorf(common.T("带密码的密钥解析失败（密码可能错误）: %w"), err)
 			}
 		} else {
 			signer, err = ssh.ParsePrivateKey(key)
 			if err != nil {
-				return fmt.Errorf("密钥文件解析失败: %w", err)
+				return fmt.Errorf(common.T("密钥文件解析失败: %w"), err)
 			}
 		}
 		auth = append(auth, ssh.PublicKeys(signer))
@@ -135,11 +140,11 @@ func (m *Machine) DoCommand(command Command) (string, error) {
 		session.Stdout = &stdoutBuf
 		stdin, err := session.StdinPipe()
 		if err != nil {
-			return nil, fmt.Errorf("获取标准输入失败: %w", err)
+			return nil, fmt.Errorf(common.T("获取标准输入失败: %w"), err)
 		}
 
 		if err = session.Start(cmd); err != nil {
-			return nil, fmt.Errorf("启动命令失败: %w", err)
+			return nil, fmt.Errorf(common.T("启动命令失败: %w"), err)
 		}
 
 		go func() {
@@ -149,7 +154,7 @@ func (m *Machine) DoCommand(command Command) (string, error) {
 		}()
 
 		if err = session.Wait(); err != nil {
-			return nil, fmt.Errorf("命令执行失败: %w", err)
+			return nil, fmt.Errorf(common.T("命令执行失败: %w"), err)
 		}
 		return stdoutBuf.Bytes(), nil
 	}
@@ -175,6 +180,7 @@ func (m *Machine) DoCommand(command Command) (string, error) {
 func (m *Machine) Down() {
 	if m.Client != nil {
 		_ = m.Client.Close()
+		m.Client = nil
 	}
 }
 
@@ -188,27 +194,28 @@ func (m *Machine) GetTasks() []AbstractTask {
 	generalTasks := []AbstractTask{&OsInfoTask{Machine: m}}
 	switch m.Type {
 	case common.JumpServer:
-		generalTasks = append(generalTasks, &ServiceTask{Machine: m})
+		generalTasks = append(generalTasks, &ServiceTask{Machine: m}, &ConfigAuditTask{Machine: m})
 	}
 	return generalTasks
 }
 
 type AbnormalMsg struct {
-	Level        string
-	Desc         string
-	NodeName     string
-	LevelDisplay string
+	Level        string `json:"level"`
+	Desc         string `json:"desc"`
+	NodeName     string `json:"node_name"`
+	LevelDisplay string `json:"level_display"`
+	// 产生该异常的任务名称，旧版本报告中没有此字段
+	Task string `json:"task,omitempty"`
 }
 
 type AbstractTask interface {
 	Init(options *Options) error
 	GetName() string
 	Run() error
-	GetResult() (map[string]interface{}, []AbnormalMsg)
+	GetResult() (interface{}, []AbnormalMsg)
 }
 
 type Task struct {
-	result         map[string]interface{}
 	abnormalResult []AbnormalMsg
 
 	Machine   *Machine
@@ -227,32 +234,33 @@ type Executor struct {
 	Machine *Machine
 	Tasks   []AbstractTask
 
-	Result         map[string]interface{}
+	Result         *MachineResult
 	AbnormalResult []AbnormalMsg
-	Logger         *common.Logger
+	TaskRecords    []TaskRecord
+	Logger         common.Printer
 }
 
-func (e *Executor) Execute(opts *Options) (map[string]interface{}, []AbnormalMsg) {
+func (e *Executor) Execute(opts *Options) (*MachineResult, []AbnormalMsg) {
 	e.Logger.Info("开始执行机器名为 [%s] 的任务，共%v个", e.Machine.Name, len(e.Tasks))
-	e.Result = make(map[string]interface{})
+	e.Result = &MachineResult{MachineType: e.Machine.Type, MachineName: e.Machine.Name}
 	for _, t := range e.Tasks {
-		e.MergeResult(DoTask(t, opts))
+		result, abnormalResult, record := DoTask(t, opts)
+		e.MergeResult(result, abnormalResult)
+		record.NodeName = e.Machine.Name
+		e.TaskRecords = append(e.TaskRecords, record)
 	}
 	e.Machine.Down()
 	e.Logger.Info("机器名为 [%s] 的任务全部执行结束\n", e.Machine.Name)
 	return e.Result, e.AbnormalResult
 }
 
-func (e *Executor) MergeResult(result map[string]interface{}, abnormalResult []AbnormalMsg) {
-	for key, value := range result {
-		e.Result[key] = value
-	}
+func (e *Executor) MergeResult(result interface{}, abnormalResult []AbnormalMsg) {
+	e.Result.Merge(result)
 	e.AbnormalResult = append(e.AbnormalResult, abnormalResult...)
 }
 
 func (t *Task) Init(opts *Options) error {
 	t.Options = opts
-	t.result = make(map[string]interface{})
 	return nil
 }
 
@@ -272,10 +280,10 @@ func (t *Task) SetAbnormalEvent(desc, level string) {
 	displayMap[common.Slight] = "轻微"
 
 	t.abnormalResult = append(t.abnormalResult, AbnormalMsg{
-		Level: level, Desc: desc, LevelDisplay: displayMap[level],
+		Level: level, Desc: desc, LevelDisplay: common.T(displayMap[level]),
 	})
 }
 
-func (t *Task) GetResult() (map[string]interface{}, []AbnormalMsg) {
-	return t.result, t.abnormalResult
+func (t *Task) GetAbnormalResult() []AbnormalMsg {
+	return t.abnormalResult
 }
@@ -0,0 +1,428 @@
+package task
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"inspect/pkg/common"
+)
+
+const (
+	// 只读取该时间范围内的任务执行记录
+	celeryExecutionDays = 31
+	// 距最近一次成功执行超过执行周期的倍数时视为未按时执行
+	periodicTaskTolerance = 2
+	periodicTaskGrace     = 10 * time.Minute
+	celeryTimeFormat      = "2006-01-02 15:04:05"
+)
+
+// CeleryWorkerInfo 单个 JumpServer 节点上 Celery 容器及进程的运行情况
+type CeleryWorkerInfo struct {
+	NodeName        string `json:"node_name"`
+	Container       string `json:"container,omitempty"`
+	ContainerStatus string `json:"container_status,omitempty"`
+	WorkerProcesses int    `json:"worker_processes"`
+	BeatRunning     bool   `json:"beat_running"`
+	Error           string `json:"error,omitempty"`
+}
+
+// PeriodicTaskInfo 周期任务的执行周期及最近执行情况，时间均为 UTC
+type PeriodicTaskInfo struct {
+	Name            string     `json:"name"`
+	Task            string     `json:"task"`
+	Schedule        string     `json:"schedule"`
+	IntervalSeconds int64      `json:"interval_seconds"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt   *time.Time `json:"last_success_at,omitempty"`
+	LastState       string     `json:"last_state,omitempty"`
+	Overdue         bool       `json:"overdue"`
+}
+
+type CeleryResult struct {
+	Workers       []CeleryWorkerInfo `json:"workers"`
+	PeriodicTasks []PeriodicTaskInfo `json:"periodic_tasks"`
+	// 周期任务查询失败的原因，如表不存在
+	PeriodicError string `json:"periodic_error,omitempty"`
+}
+
+func (w CeleryWorkerInfo) ContainerDisplay() string {
+	if w.Error != "" {
+		return common.Tf("检查失败: %s", w.Error)
+	}
+	return fmt.Sprintf("%s (%s)", w.Container, w.ContainerStatus)
+}
+
+func celeryTimeDisplay(t *time.Time) string {
+	if t == nil {
+		return common.EmptyFlag
+	}
+	return t.Local().Format(celeryTimeFormat)
+}
+
+func (p PeriodicTaskInfo) LastRunDisplay() string {
+	return celeryTimeDisplay(p.LastRunAt)
+}
+
+func (p PeriodicTaskInfo) LastSuccessDisplay() string {
+	return celeryTimeDisplay(p.LastSuccessAt)
+}
+
+func (p PeriodicTaskInfo) LastStateDisplay() string {
+	if p.LastState == "" {
+		return common.EmptyFlag
+	}
+	return p.LastState
+}
+
+// CeleryTask 检查各节点 Celery 的运行情况，并根据数据库中的执行记录检查周期任务是否按时执行
+type CeleryTask struct {
+	Task
+
+	client RDSClient
+	result *CeleryResult
+}
+
+func (t *CeleryTask) Init(opts *Options) error {
+	t.Options = opts
+	t.result = &CeleryResult{}
+	if !opts.EnableCelery {
+		return nil
+	}
+	client, err := opts.GetRDSClient()
+	if err != nil {
+		return err
+	}
+	t.client = client
+	return nil
+}
+
+// countProcesses 统计 docker top 输出中 Celery worker 及 beat 的进程数
+func countProcesses(output string) (int, bool) {
+	var workers int
+	var beat bool
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "celery") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for _, field := range fields {
+			switch field {
+			case "worker":
+				workers++
+			case "beat":
+				beat = true
+			}
+		}
+	}
+	return workers, beat
+}
+
+// checkWorker v2 版本没有单独的 jms_celery 容器，Celery 运行在 jms_core 中
+func (t *CeleryTask) checkWorker(m *Machine) CeleryWorkerInfo {
+	info := CeleryWorkerInfo{NodeName: m.Name}
+	if m.Client == nil {
+		if err := m.Connect(); err != nil {
+			m.Down()
+			info.Error = err.Error()
+			return info
+		}
+	}
+	for _, container := range []string{"jms_celery", "jms_core"} {
+		cmd := fmt.Sprintf(`docker inspect --format '{{.State.Status}}' %s`, container)
+		status, err := m.DoCommand(Command{content: cmd, timeout: 5})
+		if err != nil {
+			continue
+		}
+		info.Container, info.ContainerStatus = container, status
+		break
+	}
+	if info.Container == "" {
+		info.Error = common.T("未找到 jms_celery 容器")
+		t.setAbnormal(m.Name, common.Tf("节点 %s 上未找到 jms_celery 容器", m.Name), common.Critical)
+		return info
+	}
+	if info.ContainerStatus != "running" {
+		t.setAbnormal(m.Name, common.Tf(
+			"节点 %s 的 %s 容器未运行，当前状态为 %s", m.Name, info.Container, info.ContainerStatus,
+		), common.Critical)
+		return info
+	}
+	cmd := fmt.Sprintf(`docker top %s -eo args`, info.Container)
+	output, err := m.DoCommand(Command{content: cmd, timeout: 5})
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.WorkerProcesses, info.BeatRunning = countProcesses(output)
+	if info.WorkerProcesses == 0 {
+		t.setAbnormal(m.Name, common.Tf("节点 %s 上未检测到 Celery worker 进程", m.Name), common.Critical)
+	}
+	return info
+}
+
+func (t *CeleryTask) setAbnormal(node, desc, level string) {
+	t.SetAbnormalEvent(desc, level)
+	t.abnormalResult[len(t.abnormalResult)-1].NodeName = node
+}
+
+// parseDBTime MySQL 未开启 parseTime 时返回的是字符串，时间均按 UTC 处理
+func parseDBTime(value any) *time.Time {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		utc := v.UTC()
+		return &utc
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999", time.RFC3339Nano} {
+		if result, err := time.Parse(layout, text); err == nil {
+			result = result.UTC()
+			return &result
+		}
+	}
+	return nil
+}
+
+// queryTimes 查询任务名称及对应的时间
+func (t *CeleryTask) queryTimes(query string) (map[string]*time.Time, error) {
+	rows, err := t.client.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	result := make(map[string]*time.Time)
+	for rows.Next() {
+		var name string
+		var value any
+		if err = rows.Scan(&name, &value); err != nil {
+			continue
+		}
+		result[name] = parseDBTime(value)
+	}
+	return result, rows.Err()
+}
+
+func (t *CeleryTask) queryLastStates(since string) map[string]string {
+	query := "SELECT e.name, e.state FROM ops_celerytaskexecution e JOIN (" +
+		"SELECT name, MAX(date_published) AS latest FROM ops_celerytaskexecution " +
+		"WHERE date_published > '" + since + "' GROUP BY name" +
+		") l ON e.name = l.name AND e.date_published = l.latest"
+	result := make(map[string]string)
+	rows, err := t.client.Query(query)
+	if err != nil {
+		return result
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	for rows.Next() {
+		var name, state string
+		if err = rows.Scan(&name, &state); err == nil {
+			result[name] = state
+		}
+	}
+	return result
+}
+
+var intervalPeriods = map[string]struct {
+	unit time.Duration
+	name string
+}{
+	"days": {24 * time.Hour, "天"}, "hours": {time.Hour, "小时"}, "minutes": {time.Minute, "分钟"},
+	"seconds": {time.Second, "秒"}, "microseconds": {time.Microsecond, "微秒"},
+}
+
+// crontabInterval 估算 crontab 相邻两次执行的最大间隔
+func crontabInterval(minute, hour, dayOfWeek, dayOfMonth, monthOfYear string) time.Duration {
+	step := func(field string, unit time.Duration) time.Duration {
+		if n, err := strconv.Atoi(strings.TrimPrefix(field, "*/")); err == nil && strings.HasPrefix(field, "*/") {
+			return time.Duration(n) * unit
+		}
+		return 0
+	}
+	switch {
+	case monthOfYear != "*":
+		return 366 * 24 * time.Hour
+	case dayOfMonth != "*":
+		return 31 * 24 * time.Hour
+	case dayOfWeek != "*":
+		return 7 * 24 * time.Hour
+	case hour != "*":
+		if d := step(hour, time.Hour); d > 0 {
+			return d
+		}
+		return 24 * time.Hour
+	case minute == "*":
+		return time.Minute
+	default:
+		if d := step(minute, time.Minute); d > 0 {
+			return d
+		}
+		return time.Hour
+	}
+}
+
+// GetPeriodicTasks 读取 django_celery_beat 中启用的周期任务及各任务最近的执行记录
+func (t *CeleryTask) GetPeriodicTasks() error {
+	query := "SELECT p.name, p.task, p.last_run_at, i.every, i.period, " +
+		"c.minute, c.hour, c.day_of_week, c.day_of_month, c.month_of_year " +
+		"FROM django_celery_beat_periodictask p " +
+		"LEFT JOIN django_celery_beat_intervalschedule i ON p.interval_id = i.id " +
+		"LEFT JOIN django_celery_beat_crontabschedule c ON p.crontab_id = c.id " +
+		"WHERE p.enabled = true AND p.one_off = false ORDER BY p.name"
+	rows, err := t.client.Query(query)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	var tasks []PeriodicTaskInfo
+	for rows.Next() {
+		var info PeriodicTaskInfo
+		var lastRun any
+		var every sql.NullInt64
+		var period, minute, hour, dayOfWeek, dayOfMonth, monthOfYear sql.NullString
+		err = rows.Scan(
+			&info.Name, &info.Task, &lastRun, &every, &period,
+			&minute, &hour, &dayOfWeek, &dayOfMonth, &monthOfYear,
+		)
+		if err != nil {
+			continue
+		}
+		info.LastRunAt = parseDBTime(lastRun)
+		var interval time.Duration
+		switch {
+		case every.Valid:
+			// 未知的周期单位无法计算间隔，按 0 处理会导致每次巡检都判断为超时
+			p, ok := intervalPeriods[period.String]
+			if !ok || every.Int64 <= 0 {
+				continue
+			}
+			interval = time.Duration(every.Int64) * p.unit
+			info.Schedule = common.Tf("每 %d %s", every.Int64, common.T(p.name))
+		case minute.Valid:
+			interval = crontabInterval(minute.String, hour.String, dayOfWeek.String, dayOfMonth.String, monthOfYear.String)
+			info.Schedule = strings.Join([]string{
+				minute.String, hour.String, dayOfMonth.String, monthOfYear.String, dayOfWeek.String,
+			}, " ")
+		default:
+			// 按时间点执行等其他调度方式无法判断是否超时
+			continue
+		}
+		info.IntervalSeconds = int64(interval.Seconds())
+		tasks = append(tasks, info)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -celeryExecutionDays).Format(celeryTimeFormat)
+	successes, err := t.queryTimes(
+		"SELECT name, MAX(date_finished) FROM ops_celerytaskexecution " +
+			"WHERE state = 'SUCCESS' AND date_published > '" + since + "' GROUP BY name",
+	)
+	if err != nil {
+		return err
+	}
+	// 旧版本没有 beat 的调度时间时，以任务最近的发布时间代替
+	published, _ := t.queryTimes("SELECT name, date_last_publish FROM ops_celerytask")
+	states := t.queryLastStates(since)
+	now := time.Now()
+	for i := range tasks {
+		task := &tasks[i]
+		task.LastSuccessAt = successes[task.Task]
+		task.LastState = states[task.Task]
+		if task.LastRunAt == nil {
+			task.LastRunAt = published[task.Task]
+		}
+		interval := time.Duration(task.IntervalSeconds) * time.Second
+		deadline := interval*periodicTaskTolerance + periodicTaskGrace
+		switch {
+		case task.LastSuccessAt != nil:
+			task.Overdue = now.Sub(*task.LastSuccessAt) > deadline
+		default:
+			// 执行周期超过查询范围的任务，没有记录不代表未执行
+			task.Overdue = deadline < celeryExecutionDays*24*time.Hour
+		}
+		if task.Overdue {
+			t.SetAbnormalEvent(common.Tf(
+				"周期任务 %s 未按时执行(周期: %s)，最近一次成功执行时间: %s",
+				task.Name, task.Schedule, task.LastSuccessDisplay(),
+			), common.Alert)
+		} else if task.LastState == "FAILURE" {
+			t.SetAbnormalEvent(common.Tf("周期任务 %s 最近一次执行失败", task.Name), common.Normal)
+		}
+	}
+	t.result.PeriodicTasks = tasks
+	return nil
+}
+
+func (t *CeleryTask) GetResult() (interface{}, []AbnormalMsg) {
+	return t.result, t.abnormalResult
+}
+
+func (t *CeleryTask) GetName() string {
+	return "Celery 任务检查"
+}
+
+func (t *CeleryTask) Run() error {
+	if !t.Options.EnableCelery {
+		return nil
+	}
+	// serve 模式下会多次巡检，执行结束后需要释放连接
+	defer func(client RDSClient) {
+		_ = client.Close()
+	}(t.client)
+	var checked, beat bool
+	for i := range t.Options.MachineSet {
+		m := &t.Options.MachineSet[i]
+		if m.Type != common.JumpServer {
+			continue
+		}
+		info := t.checkWorker(m)
+		t.result.Workers = append(t.result.Workers, info)
+		checked = checked || info.Error == ""
+		beat = beat || info.BeatRunning
+	}
+	if checked && !beat {
+		t.SetAbnormalEvent(common.T("所有节点上均未检测到 Celery beat 进程，周期任务将不会被调度"), common.Critical)
+	}
+	if err := t.GetPeriodicTasks(); err != nil {
+		t.result.PeriodicError = err.Error()
+		return err
+	}
+	return nil
+}
+
+// celeryMetrics worker 进程数及周期任务是否超时
+func (r *ResultSummary) celeryMetrics(c *metricCollector) {
+	if r.CeleryResult == nil {
+		return
+	}
+	for _, w := range r.CeleryResult.Workers {
+		if w.Error != "" {
+			continue
+		}
+		c.metrics = append(c.metrics, Metric{
+			Name: "celery_worker_processes", Node: w.NodeName,
+			Value: float64(w.WorkerProcesses), Unit: UnitCount, Display: strconv.Itoa(w.WorkerProcesses),
+		})
+	}
+	for _, p := range r.CeleryResult.PeriodicTasks {
+		c.metrics = append(c.metrics, Metric{
+			Name: "celery_periodic_task_overdue", Labels: map[string]string{"task": p.Name},
+			Value: boolValue(p.Overdue), Unit: UnitBool, Display: common.BoolDisplay(p.Overdue),
+		})
+	}
+}
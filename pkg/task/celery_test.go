@@ -0,0 +1,109 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCrontabInterval(t *testing.T) {
+	cases := []struct {
+		name string
+		expr [5]string // minute hour day_of_week day_of_month month_of_year
+		want time.Duration
+	}{
+		{"每分钟", [5]string{"*", "*", "*", "*", "*"}, time.Minute},
+		{"每 5 分钟", [5]string{"*/5", "*", "*", "*", "*"}, 5 * time.Minute},
+		{"每小时固定分钟", [5]string{"30", "*", "*", "*", "*"}, time.Hour},
+		{"多个分钟按小时估算", [5]string{"0,30", "*", "*", "*", "*"}, time.Hour},
+		{"每 2 小时", [5]string{"0", "*/2", "*", "*", "*"}, 2 * time.Hour},
+		{"每天", [5]string{"0", "2", "*", "*", "*"}, 24 * time.Hour},
+		{"每周", [5]string{"0", "2", "1", "*", "*"}, 7 * 24 * time.Hour},
+		{"每月", [5]string{"0", "2", "*", "1", "*"}, 31 * 24 * time.Hour},
+		{"每年", [5]string{"0", "2", "*", "1", "1"}, 366 * 24 * time.Hour},
+		{"步长无法解析", [5]string{"*/x", "*", "*", "*", "*"}, time.Hour},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := c.expr
+			if got := crontabInterval(e[0], e[1], e[2], e[3], e[4]); got != c.want {
+				t.Errorf("crontabInterval(%v) = %v, want %v", e, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCountProcesses(t *testing.T) {
+	cases := []struct {
+		name    string
+		output  string
+		workers int
+		beat    bool
+	}{
+		{
+			name: "worker 及 beat",
+			output: "COMMAND\n" +
+				"/usr/local/bin/python /usr/local/bin/celery -A ops worker -P threads -l INFO -Q celery\n" +
+				"/usr/local/bin/python /usr/local/bin/celery -A ops worker -P threads -l INFO -Q ansible\n" +
+				"/usr/local/bin/python /usr/local/bin/celery -A ops beat -l INFO --scheduler ops.celery.beat\n",
+			workers: 2, beat: true,
+		},
+		{
+			name:    "v2 版本的 jms_core",
+			output:  "COMMAND\npython jms start all\ngunicorn jumpserver.asgi:application\ncelery -A ops worker -l INFO\n",
+			workers: 1,
+		},
+		{
+			name:   "只有 beat",
+			output: "COMMAND\ncelery -A ops beat -l INFO\n",
+			beat:   true,
+		},
+		{
+			name:   "非 celery 进程中的关键字",
+			output: "COMMAND\n/bin/bash /opt/worker beat\npython manage.py runserver\n",
+		},
+		{name: "空输出", output: ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			workers, beat := countProcesses(c.output)
+			if workers != c.workers || beat != c.beat {
+				t.Errorf("countProcesses = %d, %v, want %d, %v", workers, beat, c.workers, c.beat)
+			}
+		})
+	}
+}
+
+func TestParseDBTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 10, 20, 30, 123456000, time.UTC)
+	cases := []struct {
+		name  string
+		value any
+		want  *time.Time
+	}{
+		{"time.Time 转为 UTC", want.In(time.FixedZone("CST", 8*3600)), &want},
+		{"MySQL 字节串", []byte("2024-05-01 10:20:30.123456"), &want},
+		{"MySQL 字符串", "2024-05-01 10:20:30.123456", &want},
+		{"不带小数秒", "2024-05-01 10:20:30", timePtr(want.Truncate(time.Second))},
+		{"RFC3339 带时区", "2024-05-01T18:20:30.123456+08:00", &want},
+		{"NULL", nil, nil},
+		{"无法解析", "yesterday", nil},
+		{"不支持的类型", int64(1714558830), nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := parseDBTime(c.value)
+			switch {
+			case got == nil || c.want == nil:
+				if got != c.want {
+					t.Errorf("parseDBTime(%v) = %v, want %v", c.value, got, c.want)
+				}
+			case !got.Equal(*c.want) || got.Location() != time.UTC:
+				t.Errorf("parseDBTime(%v) = %v, want %v", c.value, got, c.want)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"inspect/pkg/common"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"regexp"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/liushuochen/gotable"
@@ -31,6 +33,10 @@ var levelPriority = map[string]int{
 	common.Slight:   0,
 }
 
+func LevelPriority(level string) int {
+	return levelPriority[level]
+}
+
 type Command struct {
 	content      string
 	timeout      int
@@ -49,25 +55,31 @@ func (c *Command) Value() string {
 }
 
 type GlobalInfo struct {
-	Machines        []Machine
-	JMSCount        int
-	RDSCount        int
-	RedisCount      int
-	TotalCount      int
-	InspectDatetime string
-	JMSVersion      string
+	Machines        []Machine `json:"machines"`
+	JMSCount        int       `json:"jms_count"`
+	RDSCount        int       `json:"rds_count"`
+	RedisCount      int       `json:"redis_count"`
+	TotalCount      int       `json:"total_count"`
+	InspectDatetime string    `json:"inspect_datetime"`
+	InspectTime     time.Time `json:"inspect_time"`
+	JMSVersion      string    `json:"jms_version"`
 }
 
 type ResultSummary struct {
-	GlobalInfo GlobalInfo
+	GlobalInfo GlobalInfo `json:"global_info"`
 
-	AbnormalResults []AbnormalMsg
-	NormalResults   []map[string]interface{}
-	VirtualResult   map[string]interface{}
-	DBResult        map[string]interface{}
+	AbnormalResults []AbnormalMsg   `json:"abnormal_results"`
+	NormalResults   []MachineResult `json:"normal_results"`
+	VirtualResult   *SummaryResult  `json:"virtual_result"`
+	DBResult        DBResult        `json:"db_result"`
+	TaskRecords     []TaskRecord    `json:"task_records"`
+	TLSResult       []TLSCertInfo   `json:"tls_result"`
+	CeleryResult    *CeleryResult   `json:"celery_result,omitempty"`
+	StorageResult   []StorageInfo   `json:"storage_result,omitempty"`
 
 	// Other
 	EchartsData string `json:"-"`
+	TrendData   string `json:"-"`
 }
 
 func (r *ResultSummary) SetAbnormalResult(msg AbnormalMsg) {
@@ -94,7 +106,8 @@ func (r *ResultSummary) SetGlobalInfo(opts *Options) {
 		r.GlobalInfo.JMSVersion = common.Empty
 	}
 
-	r.GlobalInfo.InspectDatetime = common.CurrentDatetime("time")
+	r.GlobalInfo.InspectTime = time.Now()
+	r.GlobalInfo.InspectDatetime = r.GlobalInfo.InspectTime.Format("2006-01-02 15:04:05")
 	r.GlobalInfo.Machines = opts.MachineSet
 	for _, m := range opts.MachineSet {
 		switch m.Type {
@@ -109,8 +122,14 @@ func (r *ResultSummary) SetGlobalInfo(opts *Options) {
 	r.GlobalInfo.TotalCount = r.GlobalInfo.JMSCount + r.GlobalInfo.RedisCount + r.GlobalInfo.RDSCount
 }
 
+// ErrCanceled 执行前确认时选择不继续
+var ErrCanceled = errors.New("巡检已取消")
+
 type Options struct {
-	Logger *common.Logger
+	Logger common.Printer
+	// 交互回调，作为库使用时可替换，为空时通过终端交互
+	PasswordPrompt func(prompt string) (string, error)
+	ConfirmPrompt  func(prompt string) (bool, error)
 
 	// 命令行参数
 	Debug           bool
@@ -118,18 +137,42 @@ type Options struct {
 	JMSConfigPath   string
 	MachineInfoPath string
 	ExcludeTask     string
+	// 非交互模式下不提示输入密码及确认，缺少的密码需通过环境变量或文件提供
+	NonInteractive  bool
+	CredentialsPath string
+	// 未设置环境变量 JMS_INSPECT_VAULT_PASSWORD 时从此文件读取凭据库主密码
+	VaultPasswordFile string
+	// 输出根目录，为空时使用当前目录下的 output
+	OutputDir string
 
 	// 解析的参数
-	JMSConfig    map[string]string
-	MachineSet   []Machine
-	RDSClient    RDSClient
-	RedisClient  *redis.Client
-	EnableRedis  bool
-	EnableRDS    bool
-	DebugLogFile *common.DebugLogger
+	JMSConfig     map[string]string
+	MachineSet    []Machine
+	RDSClient     RDSClient
+	RedisClient   *redis.Client
+	EnableRedis   bool
+	EnableRDS     bool
+	EnableTLS     bool
+	EnableCelery  bool
+	EnableStorage bool
+	DebugLogFile  *common.DebugLogger
+
+	vaultPass string
+	reportDir string
+}
+
+// ReportDir 返回本次巡检的报告目录，首次调用时按输出根目录生成
+func (o *Options) ReportDir() string {
+	if o.reportDir == "" {
+		o.reportDir = common.NewOutputDir(o.OutputDir)
+	}
+	return o.reportDir
 }
 
 func (o *Options) Clear() {
+	if o.DebugLogFile != nil {
+		o.DebugLogFile.Close()
+	}
 	if o.RDSClient != nil {
 		_ = o.RDSClient.Close()
 	}
@@ -142,7 +185,11 @@ func (o *Options) PreDebug() error {
 	if o.Debug == false {
 		return nil
 	}
-	o.DebugLogFile = common.NewDebugLogger()
+	debugLogFile, err := common.NewDebugLogger(o.ReportDir())
+	if err != nil {
+		return fmt.Errorf(common.T("创建调试日志文件失败: %w"), err)
+	}
+	o.DebugLogFile = debugLogFile
 	common.AddCallback(func() {
 		o.DebugLogFile.Close()
 	})
@@ -150,26 +197,33 @@ func (o *Options) PreDebug() error {
 }
 
 func (o *Options) Transform() {
-	o.EnableRDS, o.EnableRedis = true, true
+	o.EnableRDS, o.EnableRedis, o.EnableTLS, o.EnableCelery, o.EnableStorage = true, true, true, true, true
 	for _, taskName := range strings.Split(o.ExcludeTask, ",") {
 		switch strings.TrimSpace(taskName) {
 		case "rds":
 			o.EnableRDS = false
 		case "redis":
 			o.EnableRedis = false
+		case "tls":
+			o.EnableTLS = false
+		case "celery":
+			o.EnableCelery = false
+		case "storage":
+			o.EnableStorage = false
 		}
 	}
 }
 
 func (o *Options) CheckJMSConfig() error {
 	if _, err := os.Stat(o.JMSConfigPath); err != nil {
-		return fmt.Errorf("请检查文件路径: %s，文件不存在。", o.JMSConfigPath)
+		return fmt.Errorf(common.T("请检查文件路径: %s，文件不存在。"), o.JMSConfigPath)
 	}
 
 	if config, err := common.ConfigFileToMap(o.JMSConfigPath); err != nil {
-		return fmt.Errorf("请检查文件路径: %s，解析文件失败。", o.JMSConfigPath)
+		return fmt.Errorf(common.T("请检查文件路径: %s，解析文件失败。"), o.JMSConfigPath)
 	} else {
 		o.JMSConfig = config
+		common.RegisterConfigSecrets(config)
 	}
 	return nil
 }
@@ -253,7 +307,7 @@ func (o *Options) CheckRDS() error {
 	o.RDSClient = db
 	if err = db.Ping(); err != nil {
 		o.Logger.MsgOneLine(common.NoType, "")
-		return fmt.Errorf("连接 JumpServer RDS 失败: %v", err)
+		return fmt.Errorf(common.T("连接 JumpServer RDS 失败: %v"), err)
 	}
 	return nil
 }
@@ -277,7 +331,7 @@ func (o *Options) GetSentinelRedisClient() *redis.Client {
 
 			masterInfo, err = sentinelClient.Master(sentinelInfo[0]).Result()
 			if err != nil {
-				fmt.Printf("哨兵 %s 连接失败: %s\n", host, err)
+				o.Logger.Warning("哨兵 %s 连接失败: %s", host, err)
 			}
 		}
 		if _, exist = masterInfo["ip"]; !exist {
@@ -322,7 +376,7 @@ func (o *Options) CheckRedis() error {
 	}(rdb)
 	if _, err := rdb.Ping().Result(); err != nil {
 		o.Logger.MsgOneLine(common.NoType, "")
-		return fmt.Errorf("连接 JumpServer Redis 失败: %v", err)
+		return fmt.Errorf(common.T("连接 JumpServer Redis 失败: %v"), err)
 	}
 	o.Logger.MsgOneLine(common.Success, "数据库连接测试成功\n\n")
 	return nil
@@ -338,18 +392,30 @@ func (o *Options) CheckDB() error {
 	return nil
 }
 
-func (o *Options) getPasswordFromUser(answer string) string {
-	var password string
+func (o *Options) getPasswordFromUser(answer string) (string, error) {
+	if o.PasswordPrompt != nil {
+		return o.PasswordPrompt(answer)
+	}
 	for i := 1; i < 4; i++ {
 		o.Logger.MsgOneLine(common.NoType, answer)
 		if bytePassword, err := terminal.ReadPassword(int(syscall.Stdin)); err != nil {
-			o.Logger.Error("输入有误!")
+			o.Logger.Warning("输入有误!")
 		} else {
-			password = string(bytePassword)
-			break
+			return string(bytePassword), nil
 		}
 	}
-	return password
+	return "", errors.New(common.T("输入有误!"))
+}
+
+func (o *Options) confirm(question string) (bool, error) {
+	if o.ConfirmPrompt != nil {
+		return o.ConfirmPrompt(question)
+	}
+	var answer string
+	fmt.Print(question)
+	_, _ = fmt.Scanln(&answer)
+	answer = strings.ToLower(answer)
+	return answer == "" || answer == "y" || answer == "yes", nil
 }
 
 type configYML struct {
@@ -358,15 +424,15 @@ type configYML struct {
 
 func (o *Options) CheckMachine() error {
 	if o.MachineInfoPath == "" {
-		return fmt.Errorf("待巡检机器文件路径不能为空")
+		return errors.New(common.T("待巡检机器文件路径不能为空"))
 	}
 	if _, err := os.Stat(o.MachineInfoPath); err != nil {
-		return fmt.Errorf("请检查文件路径: %s，文件不存在", o.MachineInfoPath)
+		return fmt.Errorf(common.T("请检查文件路径: %s，文件不存在"), o.MachineInfoPath)
 	}
 
 	data, err := os.ReadFile(o.MachineInfoPath)
 	if err != nil {
-		return fmt.Errorf("请检查文件路径: %s，文件不存在", o.MachineInfoPath)
+		return fmt.Errorf(common.T("请检查文件路径: %s，文件不存在"), o.MachineInfoPath)
 	}
 
 	configType := CSV
@@ -407,7 +473,7 @@ func (o *Options) CheckMachine() error {
 				continue
 			}
 			if len(row) != 6 && len(row) != 8 {
-				return fmt.Errorf("文件第 %v 行的机器配置内容不完整，请检查: %v", index+1, o.MachineInfoPath)
+				return fmt.Errorf(common.T("文件第 %v 行的机器配置内容不完整，请检查: %v"), index+1, o.MachineInfoPath)
 			}
 			name, type_, host, port := row[nameIdx], row[typeIdx], row[hostIdx], row[portIdx]
 			username, password := row[usernameIdx], row[passwordIdx]
@@ -428,39 +494,44 @@ func (o *Options) CheckMachine() error {
 		var config configYML
 		ymlErr := yaml.Unmarshal(data, &config)
 		if err != nil {
-			msg := fmt.Sprintf("%s 或者 %s", configErr, ymlErr)
-			return fmt.Errorf("读取机器模板文件 %s 失败: %s", o.MachineInfoPath, msg)
+			msg := common.Tf("%s 或者 %s", configErr, ymlErr)
+			return fmt.Errorf(common.T("读取机器模板文件 %s 失败: %s"), o.MachineInfoPath, msg)
 		}
 		allMachines = append(allMachines, config.Servers...)
 	}
 
+	// 补全密码的机器单独保存，避免密码写入自动生成的 YML 配置文件
+	machines := make([]Machine, len(allMachines))
+	copy(machines, allMachines)
+	for i := range machines {
+		machines[i].Type = strings.ToLower(machines[i].Type)
+		if err = machines[i].IsValid(); err != nil {
+			return err
+		}
+	}
+	if err = o.resolveCredentials(machines); err != nil {
+		return err
+	}
+	for i := range machines {
+		if err = o.decryptMachine(&machines[i]); err != nil {
+			return err
+		}
+		common.RegisterSecret(machines[i].Password, machines[i].SSHKeyPassphrase, machines[i].PriPwd)
+	}
+
 	var invalidMachines []Machine
 	tableTitle := []string{"名称", "类型", "主机地址", "主机端口", "主机用户名", "提权方式", "是否有效"}
+	for i, title := range tableTitle {
+		tableTitle[i] = common.T(title)
+	}
 	table, tableErr := gotable.Create(tableTitle...)
 	if tableErr != nil {
-		return fmt.Errorf("初始化表格显示器失败: [%v]", err)
+		return fmt.Errorf(common.T("初始化表格显示器失败: [%v]"), err)
 	}
-	for index, m := range allMachines {
+	for index, m := range machines {
 		valid := "x"
-		m.Type = strings.ToLower(m.Type)
-
-		if err = m.IsValid(); err != nil {
-			return err
-		}
-		if m.Password == "" && m.SSHKeyPath == "" {
-			o.Logger.MsgOneLine(common.NoType, "")
-			title := fmt.Sprintf(
-				"请输入主机为 %s(%v)，用户名 %s 的密码：",
-				m.Name, m.Host, m.Username,
-			)
-			m.Password = o.getPasswordFromUser(title)
-		}
-		if m.PriType == "su -" && m.PriPwd == "" {
-			title := fmt.Sprintf("请输入主机为 %s(%s)，root 的密码：", m.Name, m.Host)
-			m.PriPwd = o.getPasswordFromUser(title)
-		}
 		if _, ok := machineNameSet[m.Name]; ok {
-			return fmt.Errorf("待巡检机器名称重复，名称为: %s", m.Name)
+			return fmt.Errorf(common.T("待巡检机器名称重复，名称为: %s"), m.Name)
 		} else {
 			machineNameSet[m.Name] = true
 		}
@@ -489,10 +560,15 @@ func (o *Options) CheckMachine() error {
 	if configType == CSV {
 		var yamlData bytes.Buffer
 
+		// 转换后的配置文件中不保存明文密码
 		config := configYML{Servers: allMachines}
-		encoder := yaml.NewEncoder(&yamlData)
-		encoder.SetIndent(2)
-		err = encoder.Encode(config)
+		if err = o.encryptMachines(config.Servers); err != nil {
+			o.Logger.Warning("CSV 格式配置文件转为 YML 格式时加密密码失败，未生成 YML 配置文件: %s", err)
+		} else {
+			encoder := yaml.NewEncoder(&yamlData)
+			encoder.SetIndent(2)
+			err = encoder.Encode(config)
+		}
 		if err == nil {
 			newPath := filepath.Join(filepath.Dir(o.MachineInfoPath), "auto-gen-config.yml")
 			err = os.WriteFile(newPath, yamlData.Bytes(), 0644)
@@ -503,21 +579,22 @@ func (o *Options) CheckMachine() error {
 	}
 	o.Logger.MsgOneLine(common.Success, "机器检查完成，具体如下：")
 	if len(o.MachineSet) == 0 {
-		fmt.Printf("\n%s\n", table)
-		return fmt.Errorf("没有获取到有效的机器信息，请检查此文件内容: %s", o.MachineInfoPath)
+		o.Logger.MsgOneLine(common.NoType, "\n%s\n", table)
+		return fmt.Errorf(common.T("没有获取到有效的机器信息，请检查此文件内容: %s"), o.MachineInfoPath)
 	}
 	if o.Silent {
 		return nil
 	}
-	var answer string
-	fmt.Printf("\n%s\n", table)
-	fmt.Print("是否继续执行，本次任务只会执行有效资产(默认为 yes): ")
-	_, _ = fmt.Scanln(&answer)
-	answerStr := strings.ToLower(answer)
-	if answerStr == "" || answerStr == "y" || answerStr == "yes" {
+	o.Logger.MsgOneLine(common.NoType, "\n%s\n", table)
+	if o.NonInteractive {
 		return nil
-	} else {
-		os.Exit(0)
+	}
+	ok, err := o.confirm(common.T("是否继续执行，本次任务只会执行有效资产(默认为 yes): "))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCanceled
 	}
 	return nil
 }
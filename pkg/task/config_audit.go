@@ -0,0 +1,190 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"inspect/pkg/common"
+)
+
+const (
+	minSecretKeyLength      = 32
+	minBootstrapTokenLength = 16
+	minDBPasswordLength     = 8
+	// 会话有效期超过 7 天视为过长
+	maxSessionCookieAge = 7 * 24 * 3600
+)
+
+// 常见的示例或默认取值
+var defaultSecretValues = map[string]bool{
+	"changeme": true, "secret": true, "password": true, "jumpserver": true,
+	"123456": true, "12345678": true, "admin": true, "test": true,
+}
+
+// 非 JumpServer 版本号的镜像
+var thirdPartyContainers = map[string]bool{
+	"jms_mysql": true, "jms_redis": true, "jms_postgresql": true,
+}
+
+type ConfigAuditTask struct {
+	Task
+	Machine *Machine
+
+	config map[string]string
+}
+
+func (t *ConfigAuditTask) Init(opts *Options) error {
+	t.Options = opts
+	return nil
+}
+
+// loadConfig 优先读取该节点上的配置文件，读取失败时使用本地解析的配置
+func (t *ConfigAuditTask) loadConfig() {
+	cmd := fmt.Sprintf("cat %s", t.Options.JMSConfigPath)
+	if result, err := t.Machine.DoCommand(Command{content: cmd, timeout: 5}); err == nil && result != "" {
+		t.config = common.ParseConfig(strings.NewReader(result))
+		return
+	}
+	t.Options.Logger.Debug("读取机器 %s 上的配置文件失败，使用本地配置文件审计", t.Machine.Name)
+	t.config = t.Options.JMSConfig
+}
+
+func (t *ConfigAuditTask) get(key string) string {
+	return strings.TrimSpace(t.config[key])
+}
+
+func (t *ConfigAuditTask) isTrue(key string) bool {
+	value, _ := strconv.ParseBool(t.get(key))
+	return value
+}
+
+func (t *ConfigAuditTask) checkSecret(key string, minLength int) {
+	value := t.get(key)
+	switch {
+	case value == "":
+		t.SetAbnormalEvent(common.Tf("配置项 %s 未设置", key), common.Critical)
+	case defaultSecretValues[strings.ToLower(value)]:
+		t.SetAbnormalEvent(common.Tf("配置项 %s 使用了默认值", key), common.Critical)
+	case len(value) < minLength:
+		t.SetAbnormalEvent(common.Tf("配置项 %s 长度不足 %d 位", key, minLength), common.Critical)
+	}
+}
+
+// passwordStrength 密码包含的字符种类数(小写、大写、数字、特殊字符)
+func passwordStrength(password string) int {
+	var lower, upper, digit, special int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			special = 1
+		}
+	}
+	return lower + upper + digit + special
+}
+
+func (t *ConfigAuditTask) checkPassword(key string) {
+	value := t.get(key)
+	if value == "" {
+		return
+	}
+	if len(value) < minDBPasswordLength || passwordStrength(value) < 3 || defaultSecretValues[strings.ToLower(value)] {
+		t.SetAbnormalEvent(common.Tf(
+			"配置项 %s 密码强度较弱，建议至少 %d 位并包含大小写字母、数字及特殊字符中的三种", key, minDBPasswordLength,
+		), common.Alert)
+	}
+}
+
+func (t *ConfigAuditTask) checkSecurity() {
+	t.checkSecret("SECRET_KEY", minSecretKeyLength)
+	t.checkSecret("BOOTSTRAP_TOKEN", minBootstrapTokenLength)
+	t.checkPassword("DB_PASSWORD")
+	t.checkPassword("REDIS_PASSWORD")
+	if t.isTrue("DEBUG") {
+		t.SetAbnormalEvent(common.T("配置项 DEBUG 已开启，生产环境请关闭"), common.Alert)
+	}
+	if strings.EqualFold(t.get("LOG_LEVEL"), "DEBUG") {
+		t.SetAbnormalEvent(common.T("配置项 LOG_LEVEL 为 DEBUG，日志量大且可能记录敏感信息"), common.Normal)
+	}
+	if t.get("HTTPS_PORT") == "" || t.get("SSL_CERTIFICATE") == "" {
+		t.SetAbnormalEvent(common.T("未配置 HTTPS(HTTPS_PORT、SSL_CERTIFICATE)，仅通过 HTTP 明文访问"), common.Alert)
+	}
+	if t.get("DOMAINS") == "" {
+		t.SetAbnormalEvent(common.T("配置项 DOMAINS 未设置，可能导致跨域校验失败或 Host 头攻击"), common.Normal)
+	}
+}
+
+func (t *ConfigAuditTask) checkSession() {
+	if t.get("SECURITY_MAX_IDLE_TIME") == "0" {
+		t.SetAbnormalEvent(common.T("配置项 SECURITY_MAX_IDLE_TIME 为 0，连接空闲超时已禁用"), common.Alert)
+	}
+	if value := t.get("SESSION_COOKIE_AGE"); value != "" {
+		if age, err := strconv.Atoi(value); err == nil && (age <= 0 || age > maxSessionCookieAge) {
+			t.SetAbnormalEvent(common.Tf("配置项 SESSION_COOKIE_AGE 为 %s 秒，会话有效期过长", value), common.Normal)
+		}
+	}
+	if value := t.get("SESSION_EXPIRE_AT_BROWSER_CLOSE"); value != "" && !t.isTrue("SESSION_EXPIRE_AT_BROWSER_CLOSE") {
+		t.SetAbnormalEvent(common.T("配置项 SESSION_EXPIRE_AT_BROWSER_CLOSE 已关闭，关闭浏览器后会话不失效"), common.Slight)
+	}
+}
+
+// imageTag 取镜像的标签，如 registry:5000/jumpserver/core:v3.10.9-ce 返回 v3.10.9-ce
+func imageTag(image string) string {
+	name := image[strings.LastIndex(image, "/")+1:]
+	if idx := strings.LastIndex(name, ":"); idx != -1 {
+		return name[idx+1:]
+	}
+	return "latest"
+}
+
+func (t *ConfigAuditTask) checkImageVersion() {
+	version := t.get("CURRENT_VERSION")
+	if version == "" {
+		return
+	}
+	sep := "***"
+	cmd := fmt.Sprintf(`docker ps --format "{{.Names}}%s{{.Image}}" |grep jms_`, sep)
+	result, err := t.Machine.DoCommand(Command{content: cmd, timeout: 5})
+	if err != nil {
+		return
+	}
+	var mismatched []string
+	for _, line := range strings.Split(result, "\n") {
+		name, image, found := strings.Cut(strings.TrimSpace(line), sep)
+		if !found || thirdPartyContainers[name] {
+			continue
+		}
+		// 镜像标签可能带有 -ce、-ee 等后缀
+		if tag := imageTag(image); tag != version && !strings.HasPrefix(tag, version+"-") {
+			mismatched = append(mismatched, fmt.Sprintf("%s(%s)", name, tag))
+		}
+	}
+	if len(mismatched) > 0 {
+		t.SetAbnormalEvent(common.Tf(
+			"运行中的组件镜像版本与 CURRENT_VERSION(%s) 不一致: %s", version, strings.Join(mismatched, "、"),
+		), common.Alert)
+	}
+}
+
+func (t *ConfigAuditTask) GetResult() (interface{}, []AbnormalMsg) {
+	return nil, t.abnormalResult
+}
+
+func (t *ConfigAuditTask) GetName() string {
+	return "堡垒机配置审计"
+}
+
+func (t *ConfigAuditTask) Run() error {
+	t.loadConfig()
+	t.checkSecurity()
+	t.checkSession()
+	t.checkImageVersion()
+	return nil
+}
@@ -0,0 +1,226 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"inspect/pkg/common"
+)
+
+const (
+	// 只取需要的字段，避免容器环境变量中的密码出现在输出中
+	containerInspectFormat = `{"name":{{json .Name}},"state":{{json .State}},"restart_count":{{.RestartCount}},` +
+		`"image":{{json .Config.Image}},"image_id":{{json .Image}},"memory_limit":{{.HostConfig.Memory}},"mounts":{{json .Mounts}}}`
+	containerStatsFormat = `{{.Name}}***{{.CPUPerc}}***{{.MemUsage}}***{{.MemPerc}}`
+	// 内存使用率超过该值且容器配置了内存限制时告警
+	containerMemoryAlertRate = 90
+)
+
+type containerInspect struct {
+	Name  string `json:"name"`
+	State struct {
+		Status     string
+		Restarting bool
+		OOMKilled  bool
+		Health     *struct {
+			Status        string
+			FailingStreak int
+		}
+	} `json:"state"`
+	RestartCount int    `json:"restart_count"`
+	Image        string `json:"image"`
+	ImageID      string `json:"image_id"`
+	MemoryLimit  int64  `json:"memory_limit"`
+	Mounts       []struct {
+		Source      string
+		Destination string
+		RW          bool
+	} `json:"mounts"`
+}
+
+// ImageDisplay 镜像及短 ID，如 jumpserver/core:v3.10.0 (1a2b3c4d5e6f)
+func (c Component) ImageDisplay() string {
+	if c.Image == "" {
+		return common.EmptyFlag
+	}
+	id := strings.TrimPrefix(c.ImageID, "sha256:")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return fmt.Sprintf("%s (%s)", c.Image, id)
+}
+
+// ContainerHealthDisplay 容器自身的健康检查状态，未配置健康检查的容器展示为 -
+func (c Component) ContainerHealthDisplay() string {
+	if c.ContainerHealth == "" {
+		return common.EmptyFlag
+	}
+	return c.ContainerHealth
+}
+
+func (c Component) CPUDisplay() string {
+	if c.CPUUsage == "" {
+		return common.EmptyFlag
+	}
+	return c.CPUUsage
+}
+
+// MemoryDisplay 内存使用量/限制(使用率)
+func (c Component) MemoryDisplay() string {
+	if c.MemoryUsage == "" {
+		return common.EmptyFlag
+	}
+	limit := c.MemoryLimit
+	if limit == "" {
+		limit = common.T("不限制")
+	}
+	return fmt.Sprintf("%s / %s (%s)", c.MemoryUsage, limit, c.MemoryUsageRate)
+}
+
+func (c Component) MountDisplay() string {
+	if len(c.Mounts) == 0 {
+		return common.EmptyFlag
+	}
+	return strings.Join(c.Mounts, "\n")
+}
+
+// HasContainerDetail 是否获取到了容器详情，旧版本的报告中没有该部分内容
+func (r *ServiceResult) HasContainerDetail() bool {
+	for _, c := range r.ComponentInfo {
+		if c.ContainerState != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *ServiceTask) inspectContainers(names []string) (map[string]containerInspect, error) {
+	cmd := fmt.Sprintf(`docker inspect --format '%s' %s`, containerInspectFormat, strings.Join(names, " "))
+	output, err := t.Machine.DoCommand(Command{content: cmd, timeout: 10})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]containerInspect)
+	for _, line := range strings.Split(output, "\n") {
+		var detail containerInspect
+		if err = json.Unmarshal([]byte(line), &detail); err != nil {
+			continue
+		}
+		detail.Name = strings.TrimPrefix(detail.Name, "/")
+		result[detail.Name] = detail
+	}
+	return result, nil
+}
+
+// containerStats 返回容器名称对应的 CPU 使用率、内存使用量、内存上限、内存使用率
+func (t *ServiceTask) containerStats(names []string) map[string][]string {
+	result := make(map[string][]string)
+	if len(names) == 0 {
+		return result
+	}
+	cmd := fmt.Sprintf(`docker stats --no-stream --format '%s' %s`, containerStatsFormat, strings.Join(names, " "))
+	output, err := t.Machine.DoCommand(Command{content: cmd, timeout: 15})
+	if err != nil {
+		return result
+	}
+	for _, line := range strings.Split(output, "\n") {
+		ret := strings.Split(line, "***")
+		if len(ret) != 4 {
+			continue
+		}
+		usage, limit, _ := strings.Cut(ret[2], " / ")
+		result[ret[0]] = []string{ret[1], strings.TrimSpace(usage), strings.TrimSpace(limit), ret[3]}
+	}
+	return result
+}
+
+func (t *ServiceTask) checkContainer(c *Component) {
+	switch {
+	case c.ContainerState == "restarting":
+		t.SetAbnormalEvent(common.Tf("容器 %s 处于反复重启状态，已重启 %d 次", c.ServiceName, c.RestartCount), common.Critical)
+	case c.ContainerState != "running":
+		t.SetAbnormalEvent(common.Tf("容器 %s 未运行，当前状态为 %s", c.ServiceName, c.ContainerState), common.Alert)
+	case c.RestartCount > 0:
+		t.SetAbnormalEvent(common.Tf("容器 %s 已自动重启 %d 次", c.ServiceName, c.RestartCount), common.Slight)
+	}
+	if c.ContainerHealth == "unhealthy" {
+		t.SetAbnormalEvent(common.Tf("容器 %s 健康状态为 unhealthy，健康检查连续失败 %d 次", c.ServiceName, c.FailingStreak), common.Critical)
+	}
+	if c.OOMKilled {
+		t.SetAbnormalEvent(common.Tf("容器 %s 因内存不足被终止(OOMKilled)", c.ServiceName), common.Critical)
+	}
+	if rate, ok := common.ParsePercent(c.MemoryUsageRate); ok && c.MemoryLimit != "" && rate >= containerMemoryAlertRate {
+		t.SetAbnormalEvent(common.Tf("容器 %s 内存使用率 %s 已接近限制 %s", c.ServiceName, c.MemoryUsageRate, c.MemoryLimit), common.Alert)
+	}
+}
+
+// GetContainerDetail 通过 docker inspect 及 docker stats 补充容器的健康状态、重启次数、资源占用等信息，
+// 已停止的 jms_ 容器也会被记录
+func (t *ServiceTask) GetContainerDetail() {
+	cmd := `docker ps -a --filter name=jms_ --format '{{.Names}}'`
+	output, err := t.Machine.DoCommand(Command{content: cmd, timeout: 5})
+	if err != nil || output == "" {
+		return
+	}
+	names := strings.Fields(output)
+	details, err := t.inspectContainers(names)
+	if err != nil {
+		t.SetAbnormalEvent(common.Tf("获取容器详情失败: %s", err), common.Slight)
+		return
+	}
+	var running []string
+	for _, name := range names {
+		if details[name].State.Status == "running" {
+			running = append(running, name)
+		}
+	}
+	stats := t.containerStats(running)
+
+	components := make(map[string]int)
+	for i, component := range t.result.ComponentInfo {
+		components[component.ServiceName] = i
+	}
+	for _, name := range names {
+		detail, exist := details[name]
+		if !exist {
+			continue
+		}
+		idx, listed := components[name]
+		if !listed {
+			// docker ps 只列出运行中的容器
+			t.result.ComponentInfo = append(t.result.ComponentInfo, Component{
+				ServiceName: name, ServiceStatus: detail.State.Status,
+			})
+			idx = len(t.result.ComponentInfo) - 1
+		}
+		c := &t.result.ComponentInfo[idx]
+		c.ContainerState = detail.State.Status
+		c.RestartCount = detail.RestartCount
+		c.OOMKilled = detail.State.OOMKilled
+		if detail.State.Restarting {
+			c.ContainerState = "restarting"
+		}
+		if detail.State.Health != nil {
+			c.ContainerHealth = detail.State.Health.Status
+			c.FailingStreak = detail.State.Health.FailingStreak
+		}
+		c.Image, c.ImageID = detail.Image, detail.ImageID
+		c.Mounts = nil
+		for _, mount := range detail.Mounts {
+			display := fmt.Sprintf("%s:%s", mount.Source, mount.Destination)
+			if !mount.RW {
+				display += ":ro"
+			}
+			c.Mounts = append(c.Mounts, display)
+		}
+		if stat, exist := stats[name]; exist {
+			c.CPUUsage, c.MemoryUsage, c.MemoryUsageRate = stat[0], stat[1], stat[3]
+			// 未限制内存时 docker stats 展示的上限为宿主机内存
+			if detail.MemoryLimit > 0 {
+				c.MemoryLimit = stat[2]
+			}
+		}
+		t.checkContainer(c)
+	}
+}
@@ -0,0 +1,194 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"inspect/pkg/common"
+	"inspect/pkg/vault"
+)
+
+// 未配置密码时按以下顺序获取: password_env、password_file、凭据文件、默认环境变量，
+// 仍未获取到时交互输入，非交互模式下直接报错
+
+const (
+	// PasswordEnvPrefix 默认环境变量前缀，后接机器名称(大写，非字母数字替换为下划线)
+	PasswordEnvPrefix          = "JMS_INSPECT_PASSWORD_"
+	PrivilegePasswordEnvPrefix = "JMS_INSPECT_PRIVILEGE_PASSWORD_"
+	CredentialsEnv             = "JMS_INSPECT_CREDENTIALS"
+)
+
+var envNameInvalidChars = regexp.MustCompile(`[^A-Z0-9]`)
+
+// credential 凭据文件中单台机器的密码，文件内容为以机器名称为键的 YAML
+type credential struct {
+	Password          string `yaml:"password"`
+	PrivilegePassword string `yaml:"privilege_password"`
+}
+
+// MachineEnvName 机器名称对应的默认环境变量名
+func MachineEnvName(prefix, machineName string) string {
+	return prefix + envNameInvalidChars.ReplaceAllString(strings.ToUpper(machineName), "_")
+}
+
+func (o *Options) loadCredentials() (map[string]credential, error) {
+	credentials := make(map[string]credential)
+	if o.CredentialsPath == "" {
+		return credentials, nil
+	}
+	data, err := os.ReadFile(o.CredentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf(common.T("读取凭据文件 %s 失败: %s"), o.CredentialsPath, err)
+	}
+	if err = yaml.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf(common.T("解析凭据文件 %s 失败: %s"), o.CredentialsPath, err)
+	}
+	return credentials, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf(common.T("读取密码文件 %s 失败: %s"), path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// lookupSecret 依次从指定环境变量、密码文件、凭据文件及默认环境变量中获取密码
+func lookupSecret(envName, filePath, fromCredentials, defaultEnv string) (string, error) {
+	if envName != "" {
+		if value := os.Getenv(envName); value != "" {
+			return value, nil
+		}
+	}
+	if filePath != "" {
+		return readSecretFile(filePath)
+	}
+	if fromCredentials != "" {
+		return fromCredentials, nil
+	}
+	return os.Getenv(defaultEnv), nil
+}
+
+// resolveCredentials 补全所有机器缺少的密码，非交互模式下一次性列出全部未获取到的凭据
+func (o *Options) resolveCredentials(machines []Machine) error {
+	credentials, err := o.loadCredentials()
+	if err != nil {
+		return err
+	}
+	var unresolved []string
+	for i := range machines {
+		m := &machines[i]
+		cred := credentials[m.Name]
+		if m.Password == "" && m.SSHKeyPath == "" {
+			defaultEnv := MachineEnvName(PasswordEnvPrefix, m.Name)
+			if m.Password, err = lookupSecret(m.PasswordEnv, m.PasswordFile, cred.Password, defaultEnv); err != nil {
+				return err
+			}
+			if m.Password == "" && o.NonInteractive {
+				unresolved = append(unresolved, common.Tf(
+					"机器 %s(%s) 用户 %s 的密码，可通过环境变量 %s 提供", m.Name, m.Host, m.Username, defaultEnv,
+				))
+			} else if m.Password == "" {
+				o.Logger.MsgOneLine(common.NoType, "")
+				title := common.Tf(
+					"请输入主机为 %s(%v)，用户名 %s 的密码：",
+					m.Name, m.Host, m.Username,
+				)
+				if m.Password, err = o.getPasswordFromUser(title); err != nil {
+					return err
+				}
+			}
+		}
+		if m.PriType == "su -" && m.PriPwd == "" {
+			defaultEnv := MachineEnvName(PrivilegePasswordEnvPrefix, m.Name)
+			if m.PriPwd, err = lookupSecret(m.PriPwdEnv, m.PriPwdFile, cred.PrivilegePassword, defaultEnv); err != nil {
+				return err
+			}
+			if m.PriPwd == "" && o.NonInteractive {
+				unresolved = append(unresolved, common.Tf(
+					"机器 %s(%s) root 的密码，可通过环境变量 %s 提供", m.Name, m.Host, defaultEnv,
+				))
+			} else if m.PriPwd == "" {
+				title := common.Tf("请输入主机为 %s(%s)，root 的密码：", m.Name, m.Host)
+				if m.PriPwd, err = o.getPasswordFromUser(title); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(unresolved) > 0 {
+		return errors.New(common.Tf(
+			"非交互模式下以下凭据未获取到，请通过环境变量、password_env/password_file 或凭据文件(-credentials)提供:\n%s",
+			"  - "+strings.Join(unresolved, "\n  - "),
+		))
+	}
+	return nil
+}
+
+// vaultPassword 获取凭据库主密码，只在遇到密文或需要加密时获取一次
+func (o *Options) vaultPassword() (string, error) {
+	if o.vaultPass != "" {
+		return o.vaultPass, nil
+	}
+	password, err := vault.PasswordFromEnvOrFile(o.VaultPasswordFile)
+	if err != nil {
+		return "", err
+	}
+	if password == "" && o.NonInteractive {
+		return "", fmt.Errorf(
+			common.T("非交互模式下请通过环境变量 %s 或 -vault-password-file 提供凭据库主密码"), vault.PasswordEnv,
+		)
+	}
+	if password == "" {
+		o.Logger.MsgOneLine(common.NoType, "")
+		if password, err = o.getPasswordFromUser(common.T("请输入凭据库主密码：")); err != nil {
+			return "", err
+		}
+	}
+	o.vaultPass = password
+	common.RegisterSecret(password)
+	return password, nil
+}
+
+// decryptMachine 解密机器配置中以 vault: 开头的密码
+func (o *Options) decryptMachine(m *Machine) error {
+	for _, secret := range []*string{&m.Password, &m.SSHKeyPassphrase, &m.PriPwd} {
+		if !vault.IsEncrypted(*secret) {
+			continue
+		}
+		password, err := o.vaultPassword()
+		if err != nil {
+			return err
+		}
+		if *secret, err = vault.Decrypt(*secret, password); err != nil {
+			return fmt.Errorf(common.T("机器 %s 的密码解密失败: %s"), m.Name, err)
+		}
+	}
+	return nil
+}
+
+// encryptMachines 加密 CSV 转换后的 YML 配置中的明文密码
+func (o *Options) encryptMachines(machines []Machine) error {
+	for i := range machines {
+		m := &machines[i]
+		for _, secret := range []*string{&m.Password, &m.SSHKeyPassphrase, &m.PriPwd} {
+			if *secret == "" || vault.IsEncrypted(*secret) {
+				continue
+			}
+			password, err := o.vaultPassword()
+			if err != nil {
+				return err
+			}
+			if *secret, err = vault.Encrypt(*secret, password); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package task
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"inspect/pkg/common"
+)
+
+const (
+	healthProbeTimeout = 5
+	// 响应内容只保留末尾部分用于校验
+	healthBodyLimit = 4096
+)
+
+// healthProbe 通过 Nginx 访问各组件的健康检查接口
+type healthProbe struct {
+	Name      string
+	Container string
+	Path      string
+	// 为空时只校验状态码
+	BodyPattern *regexp.Regexp
+	// 可选组件只在对应容器存在时探测
+	Optional bool
+}
+
+var healthProbes = []healthProbe{
+	{Name: "Nginx", Container: "jms_web", Path: "/"},
+	{Name: "Core", Container: "jms_core", Path: "/api/health/", BodyPattern: regexp.MustCompile(`"status"\s*:\s*true`)},
+	{Name: "KoKo", Container: "jms_koko", Path: "/koko/health/", Optional: true},
+	{Name: "Lion", Container: "jms_lion", Path: "/lion/health/", Optional: true},
+	{Name: "Chen", Container: "jms_chen", Path: "/chen/health/", Optional: true},
+	{Name: "Magnus", Container: "jms_magnus", Path: "/magnus/health/", Optional: true},
+}
+
+type healthResult struct {
+	code    int
+	latency float64
+	body    string
+}
+
+// HealthDisplay 健康检查结果的展示内容，如 200 / 35ms / 正常
+func (c Component) HealthDisplay() string {
+	if c.HealthPassed == nil {
+		return common.EmptyFlag
+	}
+	status := common.T("正常")
+	if !*c.HealthPassed {
+		status = common.Tf("异常: %s", c.HealthMessage)
+	}
+	return fmt.Sprintf("%d / %.0fms / %s", c.HealthCode, c.HealthLatency*1000, status)
+}
+
+// healthBaseURL 根据配置的端口确定访问 Nginx 的地址
+func (t *ServiceTask) healthBaseURL(host string) string {
+	if port := t.GetConfig("HTTP_PORT", ""); port != "" {
+		return fmt.Sprintf("http://%s:%s", host, port)
+	}
+	if port := t.GetConfig("HTTPS_PORT", ""); port != "" {
+		return fmt.Sprintf("https://%s:%s", host, port)
+	}
+	return fmt.Sprintf("http://%s", host)
+}
+
+// probeByCurl 在节点上执行 curl，最后一行为状态码及耗时
+func (t *ServiceTask) probeByCurl(url string) (*healthResult, error) {
+	cmd := fmt.Sprintf(
+		`curl -sk --max-time %d -w '\n%%{http_code} %%{time_total}' '%s' | tail -c %d`,
+		healthProbeTimeout, url, healthBodyLimit,
+	)
+	output, err := t.Machine.DoCommand(Command{content: cmd, timeout: healthProbeTimeout + 1})
+	if err != nil {
+		return nil, err
+	}
+	idx := strings.LastIndex(output, "\n")
+	body, trailer := "", output
+	if idx != -1 {
+		body, trailer = output[:idx], output[idx+1:]
+	}
+	fields := strings.Fields(trailer)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf(common.T("无法解析 curl 输出: %s"), trailer)
+	}
+	code, _ := strconv.Atoi(fields[0])
+	latency, _ := strconv.ParseFloat(fields[1], 64)
+	return &healthResult{code: code, latency: latency, body: body}, nil
+}
+
+// probeDirect 节点上没有 curl 时由巡检工具直接访问
+func probeDirect(url string) (*healthResult, error) {
+	client := http.Client{
+		Timeout: healthProbeTimeout * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		// 与 curl 一致，不跟随跳转
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return &healthResult{latency: time.Since(start).Seconds()}, nil
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, healthBodyLimit))
+	return &healthResult{code: resp.StatusCode, latency: time.Since(start).Seconds(), body: string(body)}, nil
+}
+
+func (t *ServiceTask) GetComponentHealth() {
+	containers := make(map[string]int)
+	for i, component := range t.result.ComponentInfo {
+		containers[component.ServiceName] = i
+	}
+	_, err := t.Machine.DoCommand(Command{content: "command -v curl", timeout: 5})
+	useCurl := err == nil
+	for _, probe := range healthProbes {
+		idx, running := containers[probe.Container]
+		if probe.Optional && !running {
+			continue
+		}
+		var result *healthResult
+		var url string
+		if useCurl {
+			url = t.healthBaseURL("127.0.0.1") + probe.Path
+			result, err = t.probeByCurl(url)
+		} else {
+			url = t.healthBaseURL(t.Machine.Host) + probe.Path
+			result, err = probeDirect(url)
+		}
+		component := Component{ServiceName: probe.Name}
+		if running {
+			component = t.result.ComponentInfo[idx]
+		}
+		component.HealthURL = url
+		passed := false
+		switch {
+		case err != nil:
+			component.HealthMessage = err.Error()
+		case result.code == 0:
+			component.HealthMessage = common.T("连接失败或超时")
+		case result.code >= 400:
+			component.HealthMessage = common.Tf("状态码 %d", result.code)
+		case probe.BodyPattern != nil && !probe.BodyPattern.MatchString(result.body):
+			component.HealthMessage = common.T("响应内容校验失败")
+		default:
+			passed = true
+		}
+		if result != nil {
+			component.HealthCode, component.HealthLatency = result.code, result.latency
+		}
+		component.HealthPassed = &passed
+		if running {
+			t.result.ComponentInfo[idx] = component
+		} else {
+			t.result.ComponentInfo = append(t.result.ComponentInfo, component)
+		}
+		if !passed {
+			level := common.Critical
+			if probe.Optional {
+				level = common.Alert
+			}
+			t.SetAbnormalEvent(common.Tf("组件 %s 健康检查失败(%s): %s", probe.Name, url, component.HealthMessage), level)
+		}
+	}
+}
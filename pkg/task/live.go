@@ -0,0 +1,137 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"inspect/pkg/common"
+)
+
+// serve 模式下按各自的间隔周期执行的轻量级采集项，结果用于 /metrics 接口
+
+const (
+	CollectorRedis   = "redis"
+	CollectorSummary = "summary"
+	CollectorDocker  = "docker"
+	CollectorDisk    = "disk"
+)
+
+type LiveCollector struct {
+	Name     string
+	Interval time.Duration
+	Collect  func(opts *Options) ([]Metric, error)
+}
+
+// LiveCollectors 全部采集项及默认采集间隔
+func LiveCollectors() []LiveCollector {
+	return []LiveCollector{
+		{Name: CollectorRedis, Interval: 30 * time.Second, Collect: collectRedis},
+		{Name: CollectorSummary, Interval: 5 * time.Minute, Collect: collectSummary},
+		{Name: CollectorDocker, Interval: time.Minute, Collect: collectDocker},
+		{Name: CollectorDisk, Interval: 5 * time.Minute, Collect: collectDisk},
+	}
+}
+
+func collectRedis(opts *Options) ([]Metric, error) {
+	if !opts.EnableRedis {
+		return nil, nil
+	}
+	t := DBTask{redisClient: opts.GetRedisClient(), result: &DBResult{}}
+	t.Options = opts
+	defer func(client *redis.Client) {
+		_ = client.Close()
+	}(t.redisClient)
+	if err := t.GetRedisInfo(); err != nil {
+		return nil, err
+	}
+	c := &metricCollector{}
+	summary := ResultSummary{DBResult: *t.result}
+	summary.dbMetrics(c)
+	return c.metrics, nil
+}
+
+func collectSummary(opts *Options) ([]Metric, error) {
+	if !opts.EnableRDS {
+		return nil, nil
+	}
+	client, err := opts.GetRDSClient()
+	if err != nil {
+		return nil, err
+	}
+	defer func(client RDSClient) {
+		_ = client.Close()
+	}(client)
+	// 计数查询会忽略错误，先确认数据库可连接
+	if err = client.Ping(); err != nil {
+		return nil, err
+	}
+	t := SummaryTask{client: client, result: &SummaryResult{}}
+	t.GetJMSCount()
+	c := &metricCollector{}
+	summary := ResultSummary{VirtualResult: t.result}
+	summary.summaryMetrics(c)
+	return c.metrics, nil
+}
+
+// LiveSnapshot 复制一份供采集项使用的配置，机器只保留连接参数，不含已建立的连接，
+// 采集项与定时或 API 触发的巡检并发执行，巡检过程中对机器连接、调试日志的修改不会影响采集项
+func (o *Options) LiveSnapshot() *Options {
+	snapshot := *o
+	snapshot.MachineSet = make([]Machine, len(o.MachineSet))
+	for i, machine := range o.MachineSet {
+		machine.Client = nil
+		snapshot.MachineSet[i] = machine
+	}
+	snapshot.JMSConfig = make(map[string]string, len(o.JMSConfig))
+	for key, value := range o.JMSConfig {
+		snapshot.JMSConfig[key] = value
+	}
+	// 采集项每次单独建立数据库连接，也不写入调试日志
+	snapshot.RDSClient, snapshot.RedisClient = nil, nil
+	snapshot.Debug, snapshot.DebugLogFile = false, nil
+	return &snapshot
+}
+
+// eachMachine 每台机器单独建立 SSH 连接，opts 需为 LiveSnapshot 返回的快照
+func eachMachine(opts *Options, machineType string, collect func(m *Machine) *MachineResult) ([]Metric, error) {
+	var errs []error
+	c := &metricCollector{}
+	summary := ResultSummary{}
+	for _, machine := range opts.MachineSet {
+		if machineType != "" && machine.Type != machineType {
+			continue
+		}
+		m := machine
+		if err := m.Connect(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+			continue
+		}
+		result := collect(&m)
+		m.Down()
+		summary.machineMetrics(c, result)
+	}
+	return c.metrics, errors.Join(errs...)
+}
+
+func collectDocker(opts *Options) ([]Metric, error) {
+	return eachMachine(opts, common.JumpServer, func(m *Machine) *MachineResult {
+		t := ServiceTask{Machine: m, result: &ServiceResult{}}
+		t.Options = opts
+		t.GetJMSServiceStatus()
+		t.GetComponentHealth()
+		t.GetContainerDetail()
+		return &MachineResult{MachineType: m.Type, MachineName: m.Name, ServiceResult: t.result}
+	})
+}
+
+func collectDisk(opts *Options) ([]Metric, error) {
+	return eachMachine(opts, "", func(m *Machine) *MachineResult {
+		t := OsInfoTask{Machine: m, result: &OsInfoResult{}}
+		t.Options = opts
+		t.GetDiskInfo()
+		return &MachineResult{MachineType: m.Type, MachineName: m.Name, OsInfoResult: *t.result}
+	})
+}
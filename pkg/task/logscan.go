@@ -0,0 +1,299 @@
+package task
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"inspect/pkg/common"
+)
+
+const (
+	// 每个日志文件只扫描末尾的行数
+	logScanLines = 5000
+	// 每个日志文件最多匹配的错误行数，避免输出过大
+	logScanMaxMatches = 500
+	// 错误行之后附带的上下文行数，用于获取 Traceback 的异常信息
+	logScanContext = 30
+	// 每个组件在报告中展示的错误特征数
+	logTopSignatures = 5
+	logEvidenceLimit = 1000
+	// 错误数量达到该值时异常等级提升为一般
+	logErrorNormalCount = 100
+)
+
+// logSource 组件日志的扫描范围，Path 为 find 的 -path 过滤条件
+type logSource struct {
+	Component string
+	Dir       string
+	Name      string
+	Path      string
+	NotPath   string
+}
+
+var (
+	logErrorPattern = `ERROR|CRITICAL|FATAL|Traceback \(most recent call last\)|panic:|\[error\]|\[crit\]|\[alert\]|\[emerg\]`
+	logErrorRe      = regexp.MustCompile(logErrorPattern)
+	logTimeRe       = regexp.MustCompile(`\d{4}[-/]\d{2}[-/]\d{2}[ T]\d{2}:\d{2}:\d{2}([,.]\d+)?`)
+	// grep -n 输出的行号前缀，匹配行为 "行号:"，上下文行为 "行号-"
+	logLineNoRe = regexp.MustCompile(`^\d+[:-]`)
+
+	// 归一化错误特征时替换的可变内容，按顺序执行
+	logNormalizers = []struct {
+		re   *regexp.Regexp
+		repl string
+	}{
+		{logTimeRe, ""},
+		{regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`), "<uuid>"},
+		{regexp.MustCompile(`(?i)0x[0-9a-f]+`), "<hex>"},
+		{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+		{regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`), "<hex>"},
+		{regexp.MustCompile(`\d+`), "<n>"},
+		{regexp.MustCompile(`\s+`), " "},
+	}
+)
+
+type LogErrorSignature struct {
+	Signature string `json:"signature"`
+	Count     int    `json:"count"`
+	FirstSeen string `json:"first_seen,omitempty"`
+	LastSeen  string `json:"last_seen,omitempty"`
+	File      string `json:"file"`
+	// 最近一次出现时的日志摘录
+	Evidence string `json:"evidence"`
+}
+
+// ComponentLogErrors 单个组件最近日志中的错误汇总，Signatures 只保留出现次数最多的几项
+type ComponentLogErrors struct {
+	Component  string              `json:"component"`
+	Total      int                 `json:"total"`
+	Signatures []LogErrorSignature `json:"signatures"`
+}
+
+func (s LogErrorSignature) SeenDisplay() string {
+	if s.FirstSeen == "" {
+		return common.EmptyFlag
+	}
+	if s.FirstSeen == s.LastSeen {
+		return s.FirstSeen
+	}
+	return fmt.Sprintf("%s ~ %s", s.FirstSeen, s.LastSeen)
+}
+
+// componentLogPath 组件日志目录，v2 版本的 Core 日志不在 data 目录下
+func (t *ServiceTask) componentLogPath(name string) string {
+	volumeDir := t.GetConfig("VOLUME_DIR", "/")
+	version := t.GetConfig("CURRENT_VERSION", "v3")
+	subPath := strings.ToLower(name)
+	if subPath == "core" && strings.HasPrefix(version, "v2") {
+		return filepath.Join(volumeDir, subPath, "logs")
+	}
+	return filepath.Join(volumeDir, subPath, "data", "logs")
+}
+
+func (t *ServiceTask) logSources() []logSource {
+	coreDir := t.componentLogPath("Core")
+	sources := []logSource{
+		{Component: "Core", Dir: coreDir, Name: "*.log", NotPath: "*celery*"},
+		{Component: "Celery", Dir: coreDir, Name: "*.log", Path: "*celery*"},
+		// access.log 中不包含错误信息
+		{Component: "Nginx", Dir: t.componentLogPath("Nginx"), Name: "*error*.log"},
+	}
+	for _, name := range []string{
+		"KoKo", "Lion", "Chen", "Kael", "Magnus", "Panda", "Razor", "Video", "XRDP", "Facelive", "Nec",
+	} {
+		sources = append(sources, logSource{Component: name, Dir: t.componentLogPath(name), Name: "*.log"})
+	}
+	return sources
+}
+
+// scanCommand 整体通过 sh -c 执行，保证 sudo 等提权方式对循环内的命令同样生效，
+// grep -n 用于区分匹配行与上下文行，达到 -m 上限后输出的上下文行中同样可能包含错误关键字
+func (s logSource) scanCommand() string {
+	filter := fmt.Sprintf(`-name "%s"`, s.Name)
+	if s.Path != "" {
+		filter += fmt.Sprintf(` -path "%s"`, s.Path)
+	}
+	if s.NotPath != "" {
+		filter += fmt.Sprintf(` ! -path "%s"`, s.NotPath)
+	}
+	script := fmt.Sprintf(
+		`for f in $(find %s -maxdepth 2 -type f %s 2>/dev/null); do echo "==> $f <=="; `+
+			`tail -n %d "$f" | grep -n -E -m %d -A %d "%s"; done`,
+		s.Dir, filter, logScanLines, logScanMaxMatches, logScanContext, logErrorPattern,
+	)
+	return fmt.Sprintf("sh -c '%s'", script)
+}
+
+// normalizeSignature 去掉时间、ID、数字等可变内容，使同类错误归为一组
+func normalizeSignature(line string) string {
+	for _, n := range logNormalizers {
+		line = n.re.ReplaceAllString(line, n.repl)
+	}
+	line = strings.TrimSpace(line)
+	if runes := []rune(line); len(runes) > 200 {
+		line = string(runes[:200]) + "..."
+	}
+	return line
+}
+
+// logTime 日志行中的时间，统一为 2006-01-02 15:04:05 格式
+func logTime(line string) string {
+	value := logTimeRe.FindString(line)
+	if len(value) > 19 {
+		value = value[:19]
+	}
+	return strings.NewReplacer("/", "-", "T", " ").Replace(value)
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+type logErrorEntry struct {
+	signature string
+	time      string
+	file      string
+	evidence  []string
+}
+
+// parseTraceback 从 Traceback 行开始向后查找异常信息所在行，返回该行下标
+func parseTraceback(lines []string, start int) int {
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		if line == "--" || strings.HasPrefix(line, "==> ") {
+			return i - 1
+		}
+		if line == "" || isIndented(line) || strings.HasPrefix(line, "Traceback") ||
+			strings.HasPrefix(line, "During handling") || strings.HasPrefix(line, "The above exception") {
+			continue
+		}
+		return i
+	}
+	return len(lines) - 1
+}
+
+// splitGrepLine 去掉 grep -n 的行号前缀，文件头及分隔符等没有前缀的行原样返回
+func splitGrepLine(line string) (string, bool) {
+	prefix := logLineNoRe.FindString(line)
+	if prefix == "" {
+		return line, false
+	}
+	return line[len(prefix):], strings.HasSuffix(prefix, ":")
+}
+
+// parseLogErrors 解析扫描命令的输出，只有 grep 的匹配行计为错误，上下文行只用于补全 Traceback
+func parseLogErrors(output string) []logErrorEntry {
+	var entries []logErrorEntry
+	var file string
+	rawLines := strings.Split(output, "\n")
+	lines := make([]string, len(rawLines))
+	matched := make([]bool, len(rawLines))
+	for i, line := range rawLines {
+		lines[i], matched[i] = splitGrepLine(strings.TrimRight(line, "\r"))
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if !matched[i] {
+			if strings.HasPrefix(line, "==> ") && strings.HasSuffix(line, " <==") {
+				file = strings.TrimSuffix(strings.TrimPrefix(line, "==> "), " <==")
+			}
+			continue
+		}
+		if !logErrorRe.MatchString(line) {
+			continue
+		}
+		entry := logErrorEntry{signature: normalizeSignature(line), time: logTime(line), file: file, evidence: []string{line}}
+		// 错误行后紧跟 Traceback 时，以异常信息作为特征
+		tbStart := i
+		if !strings.HasPrefix(line, "Traceback") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "Traceback") {
+			tbStart = i + 1
+		}
+		if strings.HasPrefix(lines[tbStart], "Traceback") {
+			end := parseTraceback(lines, tbStart)
+			if end > tbStart {
+				// 输出在调用栈中截断时没有异常信息，仍以错误行作为特征
+				if !isIndented(lines[end]) {
+					exception := strings.TrimSpace(lines[end])
+					entry.signature = normalizeSignature(exception)
+					if tbStart > i {
+						entry.signature = normalizeSignature(line) + " | " + entry.signature
+					}
+				}
+				// 摘录保留错误行、最后一个调用栈及异常信息
+				from := end - 2
+				if from < tbStart {
+					from = tbStart
+				}
+				entry.evidence = append(entry.evidence, lines[from:end+1]...)
+				i = end
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// aggregateLogErrors 按特征汇总，日志按时间顺序写入，后出现的即为最近一次
+func aggregateLogErrors(component string, entries []logErrorEntry) ComponentLogErrors {
+	result := ComponentLogErrors{Component: component, Total: len(entries)}
+	index := make(map[string]int)
+	for _, entry := range entries {
+		evidence := strings.Join(entry.evidence, "\n")
+		if runes := []rune(evidence); len(runes) > logEvidenceLimit {
+			evidence = string(runes[:logEvidenceLimit]) + "..."
+		}
+		idx, exist := index[entry.signature]
+		if !exist {
+			result.Signatures = append(result.Signatures, LogErrorSignature{
+				Signature: entry.signature, FirstSeen: entry.time,
+			})
+			idx = len(result.Signatures) - 1
+			index[entry.signature] = idx
+		}
+		s := &result.Signatures[idx]
+		s.Count++
+		if s.FirstSeen == "" {
+			s.FirstSeen = entry.time
+		}
+		if entry.time != "" {
+			s.LastSeen = entry.time
+		}
+		s.File, s.Evidence = entry.file, evidence
+	}
+	sort.SliceStable(result.Signatures, func(i, j int) bool {
+		return result.Signatures[i].Count > result.Signatures[j].Count
+	})
+	if len(result.Signatures) > logTopSignatures {
+		result.Signatures = result.Signatures[:logTopSignatures]
+	}
+	return result
+}
+
+// GetComponentLogErrors 扫描各组件日志末尾的 ERROR、Traceback、panic 等错误
+func (t *ServiceTask) GetComponentLogErrors() {
+	var results []ComponentLogErrors
+	for _, source := range t.logSources() {
+		output, err := t.Machine.DoCommand(Command{content: source.scanCommand(), timeout: 30})
+		if err != nil || output == "" {
+			continue
+		}
+		entries := parseLogErrors(output)
+		if len(entries) == 0 {
+			continue
+		}
+		result := aggregateLogErrors(source.Component, entries)
+		results = append(results, result)
+		level := common.Slight
+		if result.Total >= logErrorNormalCount {
+			level = common.Normal
+		}
+		t.SetAbnormalEvent(common.Tf(
+			"组件 %s 最近的日志中存在 %d 条错误，出现最多的为: %s",
+			source.Component, result.Total, result.Signatures[0].Signature,
+		), level)
+	}
+	t.result.LogErrors = results
+}
@@ -0,0 +1,304 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"inspect/pkg/common"
+)
+
+func TestNormalizeSignature(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			"时间及数字",
+			"2024-05-01 10:20:30,123 ERROR [jms.tasks:35] task 12 failed",
+			"ERROR [jms.tasks:<n>] task <n> failed",
+		},
+		{
+			"UUID",
+			"ERROR session 0f5b2c3e-1a2b-4c3d-8e9f-0123456789ab not found",
+			"ERROR session <uuid> not found",
+		},
+		{
+			"IP 及端口",
+			"2024/05/01 10:20:30 [error] 31#31: *9 connect() failed, upstream: \"http://172.17.0.3:8080/\"",
+			"[error] <n>#<n>: *<n> connect() failed, upstream: \"http://<ip>/\"",
+		},
+		{"十六进制地址", "panic: runtime error at 0xc000123abc", "panic: runtime error at <hex>"},
+		{"连续空白", "ERROR   a \t b  ", "ERROR a b"},
+		{"过长内容截断", "ERROR " + strings.Repeat("x", 300), "ERROR " + strings.Repeat("x", 194) + "..."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := normalizeSignature(c.input); got != c.want {
+				t.Errorf("normalizeSignature(%q) = %q, want %q", c.input, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseTraceback(t *testing.T) {
+	cases := []struct {
+		name  string
+		lines []string
+		want  int
+	}{
+		{
+			"异常信息",
+			[]string{
+				"Traceback (most recent call last):",
+				`  File "/opt/jumpserver/apps/ops/tasks.py", line 10, in run`,
+				"    raise ValueError(msg)",
+				"ValueError: invalid",
+				"2024-05-01 10:20:31 INFO next",
+			},
+			3,
+		},
+		{
+			"链式异常取最后一个",
+			[]string{
+				"Traceback (most recent call last):",
+				`  File "a.py", line 1, in <module>`,
+				"KeyError: 'x'",
+				"",
+				"During handling of the above exception, another exception occurred:",
+				"",
+				"Traceback (most recent call last):",
+				`  File "a.py", line 3, in <module>`,
+				"RuntimeError: boom",
+			},
+			2,
+		},
+		{
+			"遇到分隔符",
+			[]string{"Traceback (most recent call last):", `  File "a.py", line 1, in <module>`, "--", "ERROR x"},
+			1,
+		},
+		{
+			"遇到文件头",
+			[]string{"Traceback (most recent call last):", `  File "a.py", line 1, in <module>`, "==> /tmp/b.log <=="},
+			1,
+		},
+		{
+			"输出在调用栈中截断",
+			[]string{"Traceback (most recent call last):", `  File "a.py", line 1, in <module>`, "    foo()"},
+			2,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := parseTraceback(c.lines, 0); got != c.want {
+				t.Errorf("parseTraceback = %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+// grepOutput 按 grep -n 的格式拼接扫描输出，匹配行以 "+" 开头，其余为上下文行
+func grepOutput(file string, lines ...string) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("==> %s <==\n", file))
+	for i, line := range lines {
+		switch {
+		case line == "--":
+			b.WriteString("--\n")
+		case strings.HasPrefix(line, "+"):
+			b.WriteString(fmt.Sprintf("%d:%s\n", i+1, line[1:]))
+		default:
+			b.WriteString(fmt.Sprintf("%d-%s\n", i+1, line))
+		}
+	}
+	return b.String()
+}
+
+func TestParseLogErrors(t *testing.T) {
+	type want struct {
+		signature string
+		time      string
+		file      string
+	}
+	cases := []struct {
+		name   string
+		output string
+		want   []want
+	}{
+		{
+			name: "Python Traceback",
+			output: grepOutput("/data/logs/jumpserver.log",
+				"+2024-05-01 10:20:30,123 ERROR [ops.tasks:35] run failed",
+				"+Traceback (most recent call last):",
+				`  File "/opt/jumpserver/apps/ops/tasks.py", line 35, in run`,
+				"    raise ConnectionError(host)",
+				"ConnectionError: 10.0.0.1:22 unreachable",
+				"2024-05-01 10:20:31,000 INFO [ops.tasks:40] done",
+			),
+			want: []want{{
+				"ERROR [ops.tasks:<n>] run failed | ConnectionError: <ip> unreachable",
+				"2024-05-01 10:20:30", "/data/logs/jumpserver.log",
+			}},
+		},
+		{
+			name: "单独的 Traceback",
+			output: grepOutput("/data/logs/celery.log",
+				"+Traceback (most recent call last):",
+				`  File "a.py", line 1, in <module>`,
+				"KeyError: 'org_id'",
+			),
+			want: []want{{"KeyError: 'org_id'", "", "/data/logs/celery.log"}},
+		},
+		{
+			name: "Go panic",
+			output: grepOutput("/data/logs/koko.log",
+				"+panic: runtime error: invalid memory address or nil pointer dereference",
+				"[signal SIGSEGV: segmentation violation code=0x1 addr=0x18 pc=0x7a1b2c]",
+				"",
+				"goroutine 123 [running]:",
+				"github.com/jumpserver/koko/pkg/proxy.(*Server).Proxy(0xc000123400)",
+			),
+			want: []want{{
+				"panic: runtime error: invalid memory address or nil pointer dereference", "", "/data/logs/koko.log",
+			}},
+		},
+		{
+			name: "nginx 错误日志",
+			output: grepOutput("/data/logs/error.log",
+				`+2024/05/01 10:20:30 [error] 31#31: *9 connect() failed (111: Connection refused), upstream: "http://172.17.0.3:8080/"`,
+				`+2024/05/01 10:21:30 [error] 31#31: *12 connect() failed (111: Connection refused), upstream: "http://172.17.0.3:8080/"`,
+			),
+			want: []want{
+				{
+					`[error] <n>#<n>: *<n> connect() failed (<n>: Connection refused), upstream: "http://<ip>/"`,
+					"2024-05-01 10:20:30", "/data/logs/error.log",
+				},
+				{
+					`[error] <n>#<n>: *<n> connect() failed (<n>: Connection refused), upstream: "http://<ip>/"`,
+					"2024-05-01 10:21:30", "/data/logs/error.log",
+				},
+			},
+		},
+		{
+			name: "分隔符及多个文件",
+			output: grepOutput("/data/logs/a.log",
+				"+2024-05-01 10:00:00 ERROR first",
+				"2024-05-01 10:00:01 INFO ok",
+				"--",
+				"+2024-05-01 11:00:00 CRITICAL second",
+			) + grepOutput("/data/logs/b.log",
+				"+2024-05-01 12:00:00 FATAL third",
+			),
+			want: []want{
+				{"ERROR first", "2024-05-01 10:00:00", "/data/logs/a.log"},
+				{"CRITICAL second", "2024-05-01 11:00:00", "/data/logs/a.log"},
+				{"FATAL third", "2024-05-01 12:00:00", "/data/logs/b.log"},
+			},
+		},
+		{
+			name: "Traceback 在分隔符处截断",
+			output: grepOutput("/data/logs/a.log",
+				"+Traceback (most recent call last):",
+				`  File "a.py", line 1, in <module>`,
+				"--",
+				"+2024-05-01 11:00:00 ERROR next",
+			),
+			want: []want{
+				{"Traceback (most recent call last):", "", "/data/logs/a.log"},
+				{"ERROR next", "2024-05-01 11:00:00", "/data/logs/a.log"},
+			},
+		},
+		{
+			name: "上下文行中的错误关键字不计数",
+			output: grepOutput("/data/logs/a.log",
+				"+2024-05-01 10:00:00 ERROR limit reached",
+				"2024-05-01 10:00:01 ERROR after max count",
+				"2024-05-01 10:00:02 CRITICAL after max count",
+			),
+			want: []want{{"ERROR limit reached", "2024-05-01 10:00:00", "/data/logs/a.log"}},
+		},
+		{
+			name:   "行内容以数字开头",
+			output: grepOutput("/data/logs/a.log", "+12:00:00 ERROR 3-4 failed", "5-6 ERROR context"),
+			want:   []want{{"<n>:<n>:<n> ERROR <n>-<n> failed", "", "/data/logs/a.log"}},
+		},
+		{name: "只有文件头", output: "==> /data/logs/a.log <==\n", want: nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entries := parseLogErrors(c.output)
+			if len(entries) != len(c.want) {
+				t.Fatalf("parseLogErrors 返回 %d 条, want %d: %+v", len(entries), len(c.want), entries)
+			}
+			for i, w := range c.want {
+				e := entries[i]
+				if e.signature != w.signature || e.time != w.time || e.file != w.file {
+					t.Errorf("entries[%d] = {%q %q %q}, want %+v", i, e.signature, e.time, e.file, w)
+				}
+			}
+		})
+	}
+}
+
+// 模拟 grep -m 达到上限：上限内的每条匹配行计数一次，之后附带的上下文行即使包含错误关键字也不计数
+func TestParseLogErrorsCount(t *testing.T) {
+	const matches, trailing = 20, 5
+	var lines []string
+	for i := 0; i < matches; i++ {
+		lines = append(lines, fmt.Sprintf("+2024-05-01 10:00:%02d ERROR request %d failed", i, i))
+	}
+	for i := 0; i < trailing; i++ {
+		lines = append(lines, fmt.Sprintf("2024-05-01 10:01:%02d ERROR request %d failed", i, i))
+	}
+	result := aggregateLogErrors("Core", parseLogErrors(grepOutput("/data/logs/jumpserver.log", lines...)))
+	if result.Total != matches {
+		t.Errorf("Total = %d, want %d", result.Total, matches)
+	}
+	if len(result.Signatures) != 1 || result.Signatures[0].Count != matches {
+		t.Errorf("Signatures = %+v, want 1 项且 Count 为 %d", result.Signatures, matches)
+	}
+}
+
+func TestAggregateLogErrors(t *testing.T) {
+	entry := func(signature, time, file, evidence string) logErrorEntry {
+		return logErrorEntry{signature: signature, time: time, file: file, evidence: []string{evidence}}
+	}
+	var entries []logErrorEntry
+	// 出现次数依次为 7、6、5、4、3、2、1，次数相同时保持出现顺序
+	for i := 7; i >= 1; i-- {
+		for j := 0; j < i; j++ {
+			entries = append(entries, entry(fmt.Sprintf("sig%d", i), "", "a.log", "line"))
+		}
+	}
+	entries = append(entries,
+		entry("timed", "", "a.log", "first without time"),
+		entry("timed", "2024-05-01 10:00:00", "a.log", "second"),
+		entry("timed", "", "b.log", "third without time"),
+		entry("timed", "2024-05-01 11:00:00", "c.log", strings.Repeat("长", logEvidenceLimit+1)),
+	)
+	result := aggregateLogErrors("Core", entries)
+	if result.Component != "Core" || result.Total != len(entries) {
+		t.Errorf("Component = %s, Total = %d, want Core, %d", result.Component, result.Total, len(entries))
+	}
+	if len(result.Signatures) != logTopSignatures {
+		t.Fatalf("Signatures 共 %d 项, want %d", len(result.Signatures), logTopSignatures)
+	}
+	wantOrder := []string{"sig7", "sig6", "sig5", "sig4", "timed"}
+	for i, s := range result.Signatures {
+		if s.Signature != wantOrder[i] {
+			t.Errorf("Signatures[%d] = %s, want %s", i, s.Signature, wantOrder[i])
+		}
+	}
+	timed := result.Signatures[4]
+	if timed.Count != 4 || timed.FirstSeen != "2024-05-01 10:00:00" || timed.LastSeen != "2024-05-01 11:00:00" {
+		t.Errorf("timed = %+v", timed)
+	}
+	if timed.File != "c.log" || timed.Evidence != strings.Repeat("长", logEvidenceLimit)+"..." {
+		t.Errorf("timed 的文件及摘录应取最近一次: %s, %d", timed.File, len([]rune(timed.Evidence)))
+	}
+	if timed.SeenDisplay() != "2024-05-01 10:00:00 ~ 2024-05-01 11:00:00" || result.Signatures[0].SeenDisplay() != common.EmptyFlag {
+		t.Errorf("SeenDisplay = %q, %q", timed.SeenDisplay(), result.Signatures[0].SeenDisplay())
+	}
+}
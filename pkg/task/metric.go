@@ -0,0 +1,302 @@
+package task
+
+import (
+	"inspect/pkg/common"
+	"strconv"
+	"strings"
+)
+
+const (
+	UnitBytes   = "bytes"
+	UnitPercent = "percent"
+	UnitCount   = "count"
+	UnitSeconds = "seconds"
+	UnitBool    = "bool"
+)
+
+var metricDescriptions = map[string]string{
+	"memory_total":                     "内存总量",
+	"memory_used":                      "内存已使用量",
+	"memory_available":                 "内存空闲",
+	"swap_total":                       "SWAP总量",
+	"swap_used":                        "SWAP已使用",
+	"swap_free":                        "SWAP空闲",
+	"zombie_process_exist":             "是否存在僵尸进程",
+	"zombie_process_count":             "僵尸进程数",
+	"disk_size":                        "磁盘大小",
+	"disk_used":                        "磁盘已使用",
+	"disk_available":                   "磁盘剩余可用",
+	"disk_usage":                       "磁盘使用率",
+	"replay_total":                     "录像空间总大小",
+	"replay_used":                      "录像存储已使用",
+	"replay_unused":                    "录像存储空闲",
+	"component_log_size":               "组件日志大小",
+	"component_up":                     "组件容器是否运行",
+	"component_log_error_count":        "组件最近日志中的错误数",
+	"component_health_up":              "组件健康检查是否通过",
+	"component_health_latency":         "组件健康检查耗时",
+	"component_restart_count":          "组件容器重启次数",
+	"component_oom_killed":             "组件容器是否因内存不足被终止",
+	"component_cpu_usage":              "组件容器CPU使用率",
+	"component_memory_usage":           "组件容器内存使用量",
+	"component_memory_usage_rate":      "组件容器内存使用率",
+	"tls_cert_expiry_days":             "TLS 证书剩余有效天数",
+	"celery_worker_processes":          "Celery worker 进程数",
+	"celery_periodic_task_overdue":     "周期任务是否未按时执行",
+	"storage_up":                       "录像及命令存储是否可用",
+	"db_size":                          "数据库大小",
+	"redis_uptime_days":                "Redis运行时间(天)",
+	"redis_connected_clients":          "Redis当前连接数",
+	"redis_max_clients":                "Redis最大连接数",
+	"redis_blocked_clients":            "Redis当前阻塞连接",
+	"redis_used_memory":                "Redis已使用内存",
+	"redis_used_memory_rss":            "Redis物理内存占用量",
+	"redis_used_memory_peak":           "Redis内存使用最大值",
+	"redis_max_memory":                 "Redis最大可用内存",
+	"redis_rejected_connections":       "Redis被拒绝连接数",
+	"redis_evicted_keys":               "Redis执行淘汰策略键个数",
+	"redis_keyspace_hits":              "Redis键命中次数",
+	"redis_keyspace_misses":            "Redis键未命中次数",
+	"user_count":                       "用户数",
+	"asset_count":                      "资产数",
+	"organization_count":               "组织数",
+	"online_session_count":             "在线会话数",
+	"max_login_count":                  "最大单日登录次数",
+	"max_login_asset_count":            "最大单日访问资产数",
+	"last1_month_login_count":          "近一月登录用户数",
+	"last1_month_connect_asset_count":  "近一月登录资产数",
+	"last1_month_upload_count":         "近一月文件上传次数",
+	"last3_month_login_count":          "近三月登录用户数",
+	"last3_month_connect_asset_count":  "近三月登录资产数",
+	"last3_month_upload_count":         "近三月文件上传数",
+	"last3_month_command_count":        "近三月命令记录数",
+	"last3_month_danger_command_count": "近三月高危命令记录数",
+	"last3_month_max_session_duration": "近三月最大会话时长",
+	"last3_month_avg_session_duration": "近三月平均会话时长",
+	"last3_month_ticket_count":         "近三月工单申请数",
+	"abnormal_count":                   "异常数量",
+	"last_run_timestamp":               "巡检时间戳",
+	"collector_success":                "采集项最近一次是否成功",
+	"collector_duration":               "采集项最近一次耗时",
+	"collector_last_run_timestamp":     "采集项最近一次执行时间戳",
+}
+
+// MetricDescription 指标的中文描述，未登记的指标直接返回指标名
+func MetricDescription(name string) string {
+	if desc, exist := metricDescriptions[name]; exist {
+		return common.T(desc)
+	}
+	return name
+}
+
+// Metric 从巡检结果中提取出的数值指标，Display 为报告中原有的展示值
+type Metric struct {
+	Name    string            `json:"name"`
+	Node    string            `json:"node,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Value   float64           `json:"value"`
+	Unit    string            `json:"unit"`
+	Display string            `json:"display"`
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+type metricCollector struct {
+	metrics []Metric
+}
+
+func (c *metricCollector) add(name, node, unit, display string, labels map[string]string) {
+	var value float64
+	var ok bool
+	switch unit {
+	case UnitBytes:
+		var size int64
+		size, ok = common.ParseSpace(display)
+		value = float64(size)
+	case UnitPercent:
+		value, ok = common.ParsePercent(display)
+	case UnitBool:
+		ok = display == common.Yes || display == common.No
+		if display == common.Yes {
+			value = 1
+		}
+	default:
+		value, ok = common.ParseCount(display)
+	}
+	if !ok {
+		return
+	}
+	c.metrics = append(c.metrics, Metric{
+		Name: name, Node: node, Labels: labels, Value: value, Unit: unit, Display: display,
+	})
+}
+
+func (r *ResultSummary) machineMetrics(c *metricCollector, m *MachineResult) {
+	node := m.MachineName
+	c.add("memory_total", node, UnitBytes, m.MemoryTotal, nil)
+	c.add("memory_used", node, UnitBytes, m.MemoryUsed, nil)
+	c.add("memory_available", node, UnitBytes, m.MemoryAvailable, nil)
+	c.add("swap_total", node, UnitBytes, m.SwapTotal, nil)
+	c.add("swap_used", node, UnitBytes, m.SwapUsed, nil)
+	c.add("swap_free", node, UnitBytes, m.SwapFree, nil)
+	c.add("zombie_process_exist", node, UnitBool, m.ExistZombie, nil)
+	c.add("zombie_process_count", node, UnitCount, m.ZombieCount, nil)
+	for _, disk := range m.DiskInfoList {
+		labels := map[string]string{"mount": disk.FileMount, "file_system": disk.FileSystem}
+		c.add("disk_size", node, UnitBytes, disk.FileSize, labels)
+		c.add("disk_used", node, UnitBytes, disk.FileUsed, labels)
+		c.add("disk_available", node, UnitBytes, disk.FileAvailable, labels)
+		c.add("disk_usage", node, UnitPercent, disk.FileUsageRate, labels)
+	}
+	if m.ServiceResult == nil {
+		return
+	}
+	c.add("replay_total", node, UnitBytes, m.ReplayTotal, nil)
+	c.add("replay_used", node, UnitBytes, m.ReplayUsed, nil)
+	c.add("replay_unused", node, UnitBytes, m.ReplayUnused, nil)
+	for _, component := range m.ComponentLogSize {
+		labels := map[string]string{"component": component.ServiceName}
+		c.add("component_log_size", node, UnitBytes, component.ServiceLogSize, labels)
+	}
+	for _, errors := range m.LogErrors {
+		c.metrics = append(c.metrics, Metric{
+			Name: "component_log_error_count", Node: node, Labels: map[string]string{"component": errors.Component},
+			Value: float64(errors.Total), Unit: UnitCount, Display: strconv.Itoa(errors.Total),
+		})
+	}
+	for _, component := range m.ComponentInfo {
+		if component.HealthPassed != nil {
+			labels := map[string]string{"component": component.ServiceName}
+			c.metrics = append(c.metrics,
+				Metric{
+					Name: "component_health_up", Node: node, Labels: labels,
+					Value: boolValue(*component.HealthPassed), Unit: UnitBool, Display: component.HealthDisplay(),
+				},
+				Metric{
+					Name: "component_health_latency", Node: node, Labels: labels,
+					Value: component.HealthLatency, Unit: UnitSeconds,
+					Display: strconv.FormatFloat(component.HealthLatency, 'f', 3, 64),
+				},
+			)
+		}
+		// 没有对应容器、只做了健康检查的组件
+		if component.ServiceName == common.Empty || component.ServiceStatus == "" {
+			continue
+		}
+		// docker ps 的状态如 Up 3 hours (healthy)，Up 开头且非 unhealthy 视为正常运行
+		up := strings.HasPrefix(component.ServiceStatus, "Up") &&
+			!strings.Contains(component.ServiceStatus, "unhealthy")
+		c.metrics = append(c.metrics, Metric{
+			Name: "component_up", Node: node, Labels: map[string]string{"component": component.ServiceName},
+			Value: boolValue(up), Unit: UnitBool, Display: component.ServiceStatus,
+		})
+		if component.ContainerState == "" {
+			continue
+		}
+		labels := map[string]string{"component": component.ServiceName}
+		c.metrics = append(c.metrics,
+			Metric{
+				Name: "component_restart_count", Node: node, Labels: labels,
+				Value: float64(component.RestartCount), Unit: UnitCount, Display: strconv.Itoa(component.RestartCount),
+			},
+			Metric{
+				Name: "component_oom_killed", Node: node, Labels: labels,
+				Value: boolValue(component.OOMKilled), Unit: UnitBool, Display: common.BoolDisplay(component.OOMKilled),
+			},
+		)
+		c.add("component_cpu_usage", node, UnitPercent, component.CPUUsage, labels)
+		c.add("component_memory_usage", node, UnitBytes, component.MemoryUsage, labels)
+		c.add("component_memory_usage_rate", node, UnitPercent, component.MemoryUsageRate, labels)
+	}
+}
+
+func (r *ResultSummary) dbMetrics(c *metricCollector) {
+	if r.DBResult.HasRDSInfo {
+		c.add("db_size", "", UnitBytes, r.DBResult.DBSize, nil)
+	}
+	if !r.DBResult.HasRedisInfo {
+		return
+	}
+	redis := r.DBResult.RedisResult
+	c.add("redis_uptime_days", "", UnitCount, redis.RedisUptime, nil)
+	c.add("redis_connected_clients", "", UnitCount, redis.RedisConnect, nil)
+	c.add("redis_max_clients", "", UnitCount, redis.RedisMaxConnect, nil)
+	c.add("redis_blocked_clients", "", UnitCount, redis.RedisBlockedConnect, nil)
+	c.add("redis_used_memory", "", UnitBytes, redis.UsedMemoryHuman, nil)
+	c.add("redis_used_memory_rss", "", UnitBytes, redis.UsedMemoryRssHuman, nil)
+	c.add("redis_used_memory_peak", "", UnitBytes, redis.UsedMemoryPeakHuman, nil)
+	c.add("redis_max_memory", "", UnitBytes, redis.MaxMemoryHuman, nil)
+	c.add("redis_rejected_connections", "", UnitCount, redis.RejectedConnections, nil)
+	c.add("redis_evicted_keys", "", UnitCount, redis.EvictedKeys, nil)
+	c.add("redis_keyspace_hits", "", UnitCount, redis.KeyspaceHits, nil)
+	c.add("redis_keyspace_misses", "", UnitCount, redis.KeyspaceMisses, nil)
+}
+
+func (r *ResultSummary) summaryMetrics(c *metricCollector) {
+	if r.VirtualResult == nil {
+		return
+	}
+	v := r.VirtualResult
+	c.add("user_count", "", UnitCount, v.UserCount, nil)
+	c.add("asset_count", "", UnitCount, v.AssetCount, nil)
+	c.add("organization_count", "", UnitCount, v.OrganizationCount, nil)
+	c.add("online_session_count", "", UnitCount, v.OnlineSession, nil)
+	c.add("max_login_count", "", UnitCount, v.MaxLoginCount, nil)
+	c.add("max_login_asset_count", "", UnitCount, v.MaxLoginAssetCount, nil)
+	c.add("last1_month_login_count", "", UnitCount, v.Last1MonthLoginCount, nil)
+	c.add("last1_month_connect_asset_count", "", UnitCount, v.Last1MonthConnectAssetCount, nil)
+	c.add("last1_month_upload_count", "", UnitCount, v.Last1MonthUploadCount, nil)
+	c.add("last3_month_login_count", "", UnitCount, v.Last3MonthLoginCount, nil)
+	c.add("last3_month_connect_asset_count", "", UnitCount, v.Last3MonthConnectAssetCount, nil)
+	c.add("last3_month_upload_count", "", UnitCount, v.Last3MonthUploadCount, nil)
+	c.add("last3_month_command_count", "", UnitCount, v.Last3MonthCommandCount, nil)
+	c.add("last3_month_danger_command_count", "", UnitCount, v.Last3MonthDangerCommandCount, nil)
+	if v.Last3MonthMaxSessionDuration != "" {
+		c.metrics = append(c.metrics, Metric{
+			Name: "last3_month_max_session_duration", Value: float64(v.Last3MonthMaxSessionSeconds),
+			Unit: UnitSeconds, Display: v.Last3MonthMaxSessionDuration,
+		})
+	}
+	if v.Last3MonthAvgSessionDuration != "" {
+		c.metrics = append(c.metrics, Metric{
+			Name: "last3_month_avg_session_duration", Value: float64(v.Last3MonthAvgSessionSeconds),
+			Unit: UnitSeconds, Display: v.Last3MonthAvgSessionDuration,
+		})
+	}
+	c.add("last3_month_ticket_count", "", UnitCount, v.Last3MonthTicketCount, nil)
+}
+
+func (r *ResultSummary) abnormalMetrics(c *metricCollector) {
+	counts := map[string]int{
+		common.Critical: 0, common.Alert: 0, common.Normal: 0, common.Slight: 0,
+	}
+	for _, msg := range r.AbnormalResults {
+		counts[msg.Level] += 1
+	}
+	for _, level := range []string{common.Critical, common.Alert, common.Normal, common.Slight} {
+		c.metrics = append(c.metrics, Metric{
+			Name: "abnormal_count", Labels: map[string]string{"level": level},
+			Value: float64(counts[level]), Unit: UnitCount, Display: strconv.Itoa(counts[level]),
+		})
+	}
+}
+
+// Metrics 将各任务结果中的展示值解析为可计算的数值，无法解析的展示值会被跳过
+func (r *ResultSummary) Metrics() []Metric {
+	c := &metricCollector{}
+	for i := range r.NormalResults {
+		r.machineMetrics(c, &r.NormalResults[i])
+	}
+	r.dbMetrics(c)
+	r.tlsMetrics(c)
+	r.celeryMetrics(c)
+	r.storageMetrics(c)
+	r.summaryMetrics(c)
+	r.abnormalMetrics(c)
+	return c.metrics
+}
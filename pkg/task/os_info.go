@@ -41,77 +41,85 @@ fi`
 type OsInfoTask struct {
 	Task
 	Machine *Machine
+
+	result *OsInfoResult
+}
+
+func (t *OsInfoTask) Init(opts *Options) error {
+	t.Options = opts
+	t.result = &OsInfoResult{}
+	return nil
 }
 
 func (t *OsInfoTask) GetHostname() {
 	cmd := Command{content: "hostname", timeout: 5}
 	if result, err := t.Machine.DoCommand(cmd); err == nil {
-		t.result["MachineHostname"] = result
+		t.result.MachineHostname = result
 	} else {
-		t.result["MachineHostname"] = common.Empty
+		t.result.MachineHostname = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetLanguage() {
 	command := Command{content: "echo $LANG", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["MachineLanguage"] = result
+		t.result.MachineLanguage = result
 	} else {
-		t.result["MachineLanguage"] = common.Empty
+		t.result.MachineLanguage = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetAllIps() {
 	command := Command{content: "hostname -I", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["MachineAddress"] = result
+		t.result.MachineAddress = result
 	} else {
-		t.result["MachineAddress"] = common.Empty
+		t.result.MachineAddress = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetOsVersion() {
 	command := Command{content: "uname -o", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["OsVersion"] = result
+		t.result.OsVersion = result
 	} else {
-		t.result["OsVersion"] = common.Empty
+		t.result.OsVersion = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetKernelVersion() {
 	command := Command{content: "uname -r", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["KernelVersion"] = result
+		t.result.KernelVersion = result
 	} else {
-		t.result["KernelVersion"] = common.Empty
+		t.result.KernelVersion = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetCpuArch() {
 	command := Command{content: "uname -m", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["CpuArch"] = result
+		t.result.CpuArch = result
 	} else {
-		t.result["CpuArch"] = common.Empty
+		t.result.CpuArch = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetCurrentDatetime() {
 	command := Command{content: "date +'%F %T'", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["CurrentTime"] = result
+		t.result.CurrentTime = result
 	} else {
-		t.result["CurrentTime"] = common.Empty
+		t.result.CurrentTime = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetLastUpTime() {
 	command := Command{content: "who -b | awk '{print $2,$3,$4}'", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["LastUpTime"] = result
+		t.result.LastUpTime = result
 	} else {
-		t.result["LastUpTime"] = common.Empty
+		t.result.LastUpTime = common.Empty
 	}
 }
 
@@ -119,11 +127,11 @@ func (t *OsInfoTask) GetOperatingTime() {
 	command := Command{content: "cat /proc/uptime | awk '{print $1}'", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
 		if seconds, err := strconv.Atoi(strings.Split(result, ".")[0]); err == nil {
-			t.result["OperatingTime"] = common.SecondDisplay(seconds)
+			t.result.OperatingTime = common.SecondDisplay(seconds)
 			return
 		}
 	}
-	t.result["OperatingTime"] = common.Empty
+	t.result.OperatingTime = common.Empty
 }
 
 func (t *OsInfoTask) GetCPUInfo() {
@@ -131,85 +139,74 @@ func (t *OsInfoTask) GetCPUInfo() {
 	coreNumCmd := `cat /proc/cpuinfo | grep "physical id" | sort | uniq | wc -l`
 	command := Command{content: coreNumCmd, timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["CpuNum"] = result
+		t.result.CpuNum = result
 	} else {
-		t.result["CpuNum"] = common.Empty
+		t.result.CpuNum = common.Empty
 	}
 	// 每物理核心数
 	physicalCmd := `lscpu | grep '^Core(s) per socket:' | awk '{print $4}'`
 	command = Command{content: physicalCmd, timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["CpuPhysicalCores"] = result
+		t.result.CpuPhysicalCores = result
 	} else {
-		t.result["CpuPhysicalCores"] = common.Empty
+		t.result.CpuPhysicalCores = common.Empty
 	}
 	// 逻辑核数
 	command = Command{
 		content: `cat /proc/cpuinfo | grep "processor" | wc -l`, timeout: 5,
 	}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["CpuLogicalCores"] = result
+		t.result.CpuLogicalCores = result
 	} else {
-		t.result["CpuLogicalCores"] = common.Empty
+		t.result.CpuLogicalCores = common.Empty
 	}
 	// CPU 型号
 	command = Command{
 		content: `cat /proc/cpuinfo | grep name | cut -f2 -d: | uniq`, timeout: 5,
 	}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["CpuModel"] = result
+		t.result.CpuModel = result
 	} else {
-		t.result["CpuModel"] = common.Empty
+		t.result.CpuModel = common.Empty
 	}
 }
 
 func (t *OsInfoTask) GetMemoryInfo() {
 	// 物理内存信息
 	command := Command{content: `free -h|grep -i mem`, timeout: 5}
-	if result, err := t.Machine.DoCommand(command); err == nil {
-		var resultList []string
-		tempList := strings.Split(result, " ")
-		for _, item := range tempList[1:] {
-			if item != "" {
-				resultList = append(resultList, item)
-			}
-		}
-		t.result["MemoryTotal"] = resultList[0]
-		t.result["MemoryUsed"] = resultList[1]
-		t.result["MemoryAvailable"] = resultList[len(resultList)-1]
+	// 输出格式为 "Mem: 总量 已用 ... 可用"，字段不足时视为获取失败
+	if result, err := t.Machine.DoCommand(command); err == nil && len(strings.Fields(result)) >= 3 {
+		resultList := strings.Fields(result)[1:]
+		t.result.MemoryTotal = resultList[0]
+		t.result.MemoryUsed = resultList[1]
+		t.result.MemoryAvailable = resultList[len(resultList)-1]
 	} else {
-		t.result["MemoryTotal"] = common.Empty
-		t.result["MemoryUsed"] = common.Empty
-		t.result["MemoryAvailable"] = common.Empty
+		t.result.MemoryTotal = common.Empty
+		t.result.MemoryUsed = common.Empty
+		t.result.MemoryAvailable = common.Empty
 	}
 	// 虚拟内存信息
 	command = Command{content: `free -h|grep -i swap`, timeout: 5}
-	if result, err := t.Machine.DoCommand(command); err == nil {
-		var resultList []string
-		tempList := strings.Split(result, " ")
-		for _, item := range tempList[1:] {
-			if item != "" {
-				resultList = append(resultList, item)
-			}
-		}
-		t.result["SwapTotal"] = resultList[0]
-		t.result["SwapUsed"] = resultList[1]
-		t.result["SwapFree"] = resultList[2]
+	if result, err := t.Machine.DoCommand(command); err == nil && len(strings.Fields(result)) >= 4 {
+		resultList := strings.Fields(result)[1:]
+		t.result.SwapTotal = resultList[0]
+		t.result.SwapUsed = resultList[1]
+		t.result.SwapFree = resultList[2]
 	} else {
-		t.result["SwapTotal"] = common.Empty
-		t.result["SwapUsed"] = common.Empty
-		t.result["SwapFree"] = common.Empty
+		t.result.SwapTotal = common.Empty
+		t.result.SwapUsed = common.Empty
+		t.result.SwapFree = common.Empty
 	}
 }
 
 type DiskInfo struct {
-	FileSystem    string
-	FileType      string
-	FileSize      string
-	FileUsed      string
-	FileAvailable string
-	FileUsageRate string
-	FileMount     string
+	FileSystem    string `json:"file_system"`
+	FileType      string `json:"file_type"`
+	FileSize      string `json:"file_size"`
+	FileUsed      string `json:"file_used"`
+	FileAvailable string `json:"file_available"`
+	FileUsageRate string `json:"file_usage_rate"`
+	FileMount     string `json:"file_mount"`
 }
 
 func (t *OsInfoTask) isFileUsageRateAlert(fileUsageRate string, standard float64) bool {
@@ -240,7 +237,7 @@ func (t *OsInfoTask) GetDiskInfo() {
 			fileMount := t.GetValueWithIndex(diskInfo, 6)
 			standard := 90.0
 			if t.isFileUsageRateAlert(fileUsageRate, standard) {
-				t.SetAbnormalEvent(fmt.Sprintf("%s 磁盘空间不足 %d%%", fileMount, int(100-standard)), common.Alert)
+				t.SetAbnormalEvent(common.Tf("%s 磁盘空间不足 %d%%", fileMount, int(100-standard)), common.Alert)
 			}
 			diskInfoList = append(diskInfoList, DiskInfo{
 				FileSystem:    t.GetValueWithIndex(diskInfo, 0),
@@ -252,9 +249,9 @@ func (t *OsInfoTask) GetDiskInfo() {
 				FileMount:     fileMount,
 			})
 		}
-		t.result["DiskInfoList"] = diskInfoList
+		t.result.DiskInfoList = diskInfoList
 	} else {
-		t.result["DiskInfoList"] = diskInfoList
+		t.result.DiskInfoList = diskInfoList
 	}
 }
 
@@ -262,35 +259,35 @@ func (t *OsInfoTask) GetSystemParams() {
 	// SELinux是否开启
 	command := Command{content: "getenforce", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["SelinuxEnable"] = result
+		t.result.SelinuxEnable = result
 	} else {
-		t.result["SelinuxEnable"] = common.Empty
+		t.result.SelinuxEnable = common.Empty
 	}
 	// 防火墙是否开启
 	command = Command{content: FirewalldScript, timeout: 0}
 	if result, err := t.Machine.DoCommand(command); err == nil {
 		enable := common.BoolDisplay(result)
-		t.result["FirewallEnable"] = enable
+		t.result.FirewallEnable = enable
 		if enable == common.No {
-			t.SetAbnormalEvent("节点下防火墙未开启", common.Critical)
+			t.SetAbnormalEvent(common.T("节点下防火墙未开启"), common.Critical)
 		}
 	} else {
-		t.result["FirewallEnable"] = common.Empty
+		t.result.FirewallEnable = common.Empty
 	}
 	// 是否开启 RSyslog
 	syslogCmd := `systemctl status rsyslog | grep active > /dev/null 2>&1;if [[ $? -eq 0 ]];then echo 1;else echo 0;fi`
 	command = Command{content: syslogCmd, timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["RsyslogEnable"] = common.BoolDisplay(result)
+		t.result.RsyslogEnable = common.BoolDisplay(result)
 	} else {
-		t.result["RsyslogEnable"] = common.Empty
+		t.result.RsyslogEnable = common.Empty
 	}
 	// 是否存在定时任务
 	command = Command{content: "ls /var/spool/cron/ |wc -l", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
-		t.result["CrontabEnable"] = common.BoolDisplay(result)
+		t.result.CrontabEnable = common.BoolDisplay(result)
 	} else {
-		t.result["CrontabEnable"] = common.Empty
+		t.result.CrontabEnable = common.Empty
 	}
 }
 
@@ -328,9 +325,9 @@ func (t *OsInfoTask) GetExposePort() {
 	command := Command{content: ssCmd, timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
 		ports := t.GetPortTidyDisplay(result)
-		t.result["ExposePort"] = ports
+		t.result.ExposePort = ports
 	} else {
-		t.result["ExposePort"] = common.Empty
+		t.result.ExposePort = common.Empty
 	}
 }
 
@@ -338,15 +335,20 @@ func (t *OsInfoTask) GetZombieProcess() {
 	command := Command{content: "ps -e -o ppid,stat | grep Z| wc -l", timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil {
 		exist := common.BoolDisplay(result)
-		t.result["ExistZombie"] = exist
+		t.result.ExistZombie = exist
+		t.result.ZombieCount = result
 		if exist == common.Yes {
-			t.SetAbnormalEvent("节点下存在僵尸进程", common.Normal)
+			t.SetAbnormalEvent(common.T("节点下存在僵尸进程"), common.Normal)
 		}
 	} else {
-		t.result["ExistZombie"] = common.Empty
+		t.result.ExistZombie = common.Empty
 	}
 }
 
+func (t *OsInfoTask) GetResult() (interface{}, []AbnormalMsg) {
+	return t.result, t.abnormalResult
+}
+
 func (t *OsInfoTask) GetName() string {
 	return "机器当前系统检查"
 }
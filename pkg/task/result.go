@@ -0,0 +1,157 @@
+package task
+
+// 各任务的结构化结果
+// 字段名与原先 map 结果中的键保持一致，模板中 {{ .MachineHostname }} 等写法无需改动
+
+type OsInfoResult struct {
+	MachineHostname string `json:"machine_hostname"`
+	MachineLanguage string `json:"machine_language"`
+	MachineAddress  string `json:"machine_address"`
+	OsVersion       string `json:"os_version"`
+	KernelVersion   string `json:"kernel_version"`
+	CpuArch         string `json:"cpu_arch"`
+	CurrentTime     string `json:"current_time"`
+	LastUpTime      string `json:"last_up_time"`
+	OperatingTime   string `json:"operating_time"`
+
+	CpuNum           string `json:"cpu_num"`
+	CpuPhysicalCores string `json:"cpu_physical_cores"`
+	CpuLogicalCores  string `json:"cpu_logical_cores"`
+	CpuModel         string `json:"cpu_model"`
+
+	// 内存相关均为 free -h 的输出，如 7.6Gi
+	MemoryTotal     string `json:"memory_total"`
+	MemoryUsed      string `json:"memory_used"`
+	MemoryAvailable string `json:"memory_available"`
+	SwapTotal       string `json:"swap_total"`
+	SwapUsed        string `json:"swap_used"`
+	SwapFree        string `json:"swap_free"`
+
+	DiskInfoList []DiskInfo `json:"disk_info_list"`
+
+	SelinuxEnable  string `json:"selinux_enable"`
+	FirewallEnable string `json:"firewall_enable"`
+	RsyslogEnable  string `json:"rsyslog_enable"`
+	CrontabEnable  string `json:"crontab_enable"`
+	ExposePort     string `json:"expose_port"`
+	ExistZombie    string `json:"exist_zombie"`
+	// 僵尸进程个数，旧版本报告中没有此字段
+	ZombieCount string `json:"zombie_count,omitempty"`
+}
+
+type ServiceResult struct {
+	ReplayPath string `json:"replay_path"`
+	// 录像空间均为 df/du 的可读输出，如 50G
+	ReplayTotal  string `json:"replay_total"`
+	ReplayUsed   string `json:"replay_used"`
+	ReplayUnused string `json:"replay_unused"`
+
+	ComponentLogSize []Component `json:"component_log_size"`
+	ComponentInfo    []Component `json:"component_info"`
+	// 组件最近日志中的错误汇总
+	LogErrors []ComponentLogErrors `json:"log_errors,omitempty"`
+}
+
+// MachineResult 单台机器的巡检结果
+// 通过匿名嵌入，模板中仍可直接使用 $m.MachineHostname、$m.ReplayPath 等字段，
+// 而 JSON 中则按 os/service 分组输出
+type MachineResult struct {
+	MachineType string `json:"machine_type"`
+	MachineName string `json:"machine_name"`
+
+	OsInfoResult   `json:"os"`
+	*ServiceResult `json:"service,omitempty"`
+}
+
+func (r *MachineResult) Merge(result interface{}) {
+	switch v := result.(type) {
+	case *OsInfoResult:
+		if v != nil {
+			r.OsInfoResult = *v
+		}
+	case *ServiceResult:
+		r.ServiceResult = v
+	}
+}
+
+type RedisResult struct {
+	// service info
+	RedisVersion string `json:"redis_version"`
+	RedisMode    string `json:"redis_mode"`
+	RedisPort    string `json:"redis_port"`
+	RedisUptime  string `json:"redis_uptime_days"`
+
+	// client info
+	RedisConnect        string `json:"redis_connect"`
+	RedisClusterConnect string `json:"redis_cluster_connect"`
+	RedisMaxConnect     string `json:"redis_max_connect"`
+	RedisBlockedConnect string `json:"redis_blocked_connect"`
+
+	// memory info，均为 Redis 的可读输出，如 1.02M
+	UsedMemoryHuman     string `json:"used_memory_human"`
+	UsedMemoryRssHuman  string `json:"used_memory_rss_human"`
+	UsedMemoryPeakHuman string `json:"used_memory_peak_human"`
+	UsedMemoryLuaHuman  string `json:"used_memory_lua_human"`
+	MaxMemoryHuman      string `json:"max_memory_human"`
+	MaxMemoryPolicy     string `json:"max_memory_policy"`
+
+	// statistics info
+	TotalConnectionsReceived string `json:"total_connections_received"`
+	TotalCommandsProcessed   string `json:"total_commands_processed"`
+	InstantaneousOpsPerSec   string `json:"instantaneous_ops_per_sec"`
+	TotalNetInputBytes       string `json:"total_net_input_bytes"`
+	TotalNetOutputBytes      string `json:"total_net_output_bytes"`
+	RejectedConnections      string `json:"rejected_connections"`
+	ExpiredKeys              string `json:"expired_keys"`
+	EvictedKeys              string `json:"evicted_keys"`
+	KeyspaceHits             string `json:"keyspace_hits"`
+	KeyspaceMisses           string `json:"keyspace_misses"`
+	PubSubChannels           string `json:"pubsub_channels"`
+	PubSubPatterns           string `json:"pubsub_patterns"`
+}
+
+type DBResult struct {
+	HasRDSInfo bool        `json:"has_rds_info"`
+	Top10Table []TableInfo `json:"top10_table"`
+	DBInfo     []RDSInfo   `json:"db_info"`
+	// 数据库数据与索引总大小，如 1.2GB
+	DBSize string `json:"db_size"`
+
+	HasRedisInfo bool `json:"has_redis_info"`
+	RedisResult  `json:"redis"`
+}
+
+type SummaryResult struct {
+	UserCount         string `json:"user_count"`
+	AssetCount        string `json:"asset_count"`
+	OnlineSession     string `json:"online_session"`
+	AssetCountDisplay string `json:"asset_count_display"`
+	OrganizationCount string `json:"organization_count"`
+	// 格式为 "次数 (日期)"
+	MaxLoginCount                string `json:"max_login_count"`
+	MaxLoginAssetCount           string `json:"max_login_asset_count"`
+	Last3MonthMaxLoginCount      string `json:"last3_month_max_login_count"`
+	Last3MonthMaxLoginAssetCount string `json:"last3_month_max_login_asset_count"`
+
+	Last1MonthLoginCount         string `json:"last1_month_login_count"`
+	Last1MonthConnectAssetCount  string `json:"last1_month_connect_asset_count"`
+	Last1MonthUploadCount        string `json:"last1_month_upload_count"`
+	Last3MonthLoginCount         string `json:"last3_month_login_count"`
+	Last3MonthConnectAssetCount  string `json:"last3_month_connect_asset_count"`
+	Last3MonthUploadCount        string `json:"last3_month_upload_count"`
+	Last3MonthCommandCount       string `json:"last3_month_command_count"`
+	Last3MonthDangerCommandCount string `json:"last3_month_danger_command_count"`
+	// 最大会话时长为数据库中的时间差原样输出，如 12:30:05，平均会话时长为换算后的展示值，秒数单独保存
+	Last3MonthMaxSessionDuration string `json:"last3_month_max_session_duration"`
+	Last3MonthMaxSessionSeconds  int64  `json:"last3_month_max_session_duration_seconds"`
+	Last3MonthAvgSessionDuration string `json:"last3_month_avg_session_duration"`
+	Last3MonthAvgSessionSeconds  int64  `json:"last3_month_avg_session_duration_seconds"`
+	Last3MonthTicketCount        string `json:"last3_month_ticket_count"`
+
+	UserLoginChart   *ChartCoordinate `json:"user_login_chart"`
+	AssetLoginChart  *ChartCoordinate `json:"asset_login_chart"`
+	ActiveUserChart  *ChartCoordinate `json:"active_user_chart"`
+	ActiveAssetChart *ChartCoordinate `json:"active_asset_chart"`
+	// ECharts 饼图所需的 JSON 字符串
+	ProtocolChart string `json:"protocol_chart"`
+}
@@ -12,38 +12,67 @@ import (
 const ComputeSpaceCommand = "du %s -sh|awk '{print $1}'"
 
 type Component struct {
-	ServiceName    string
-	ServicePort    string
-	ServiceStatus  string
-	ServiceLogSize string
+	ServiceName    string `json:"service_name"`
+	ServicePort    string `json:"service_port,omitempty"`
+	ServiceStatus  string `json:"service_status,omitempty"`
+	ServiceLogSize string `json:"service_log_size,omitempty"`
+
+	// HTTP 健康检查结果，未探测的组件为空
+	HealthURL     string  `json:"health_url,omitempty"`
+	HealthCode    int     `json:"health_code,omitempty"`
+	HealthLatency float64 `json:"health_latency_seconds,omitempty"`
+	HealthPassed  *bool   `json:"health_passed,omitempty"`
+	HealthMessage string  `json:"health_message,omitempty"`
+
+	// docker inspect、docker stats 获取的容器详情
+	ContainerState  string   `json:"container_state,omitempty"`
+	ContainerHealth string   `json:"container_health,omitempty"`
+	FailingStreak   int      `json:"failing_streak,omitempty"`
+	RestartCount    int      `json:"restart_count,omitempty"`
+	OOMKilled       bool     `json:"oom_killed,omitempty"`
+	Image           string   `json:"image,omitempty"`
+	ImageID         string   `json:"image_id,omitempty"`
+	CPUUsage        string   `json:"cpu_usage,omitempty"`
+	MemoryUsage     string   `json:"memory_usage,omitempty"`
+	MemoryLimit     string   `json:"memory_limit,omitempty"`
+	MemoryUsageRate string   `json:"memory_usage_rate,omitempty"`
+	Mounts          []string `json:"mounts,omitempty"`
 }
 
 type ServiceTask struct {
 	Task
 	Machine *Machine
+
+	result *ServiceResult
+}
+
+func (t *ServiceTask) Init(opts *Options) error {
+	t.Options = opts
+	t.result = &ServiceResult{}
+	return nil
 }
 
 func (t *ServiceTask) GetReplayPathInfo() {
 	volumeDir := t.GetConfig("VOLUME_DIR", "/")
 	replayPath := filepath.Join(volumeDir, "core", "data", "media", "replay")
-	t.result["ReplayPath"] = replayPath
+	t.result.ReplayPath = replayPath
 	// 总大小
 	cmd := fmt.Sprintf(
 		"df -h %s --output=size| awk '{if (NR > 1) {print $1}}' || echo '0'", replayPath,
 	)
 	command := Command{content: cmd, timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil && result != "" {
-		t.result["ReplayTotal"] = result
+		t.result.ReplayTotal = result
 	} else {
-		t.result["ReplayTotal"] = common.Empty
+		t.result.ReplayTotal = common.Empty
 	}
 	// 已经使用
 	cmd = fmt.Sprintf(ComputeSpaceCommand, replayPath)
 	command = Command{content: cmd, timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil && result != "" {
-		t.result["ReplayUsed"] = result
+		t.result.ReplayUsed = result
 	} else {
-		t.result["ReplayUsed"] = common.Empty
+		t.result.ReplayUsed = common.Empty
 	}
 	// 未使用
 	cmd = fmt.Sprintf(
@@ -53,37 +82,29 @@ func (t *ServiceTask) GetReplayPathInfo() {
 	command = Command{content: cmd, timeout: 5}
 	if result, err := t.Machine.DoCommand(command); err == nil && result != common.EmptyFlag {
 		if size, err := strconv.ParseInt(result, 10, 64); err != nil {
-			t.result["ReplayUnused"] = common.Empty
+			t.result.ReplayUnused = common.Empty
 		} else {
 			sizeDisplay := common.SpaceDisplay(size)
-			t.result["ReplayUnused"] = sizeDisplay
+			t.result.ReplayUnused = sizeDisplay
 			if size <= 50*1024 {
-				desc := fmt.Sprintf("录像空间大小不足，当前大小: %s", sizeDisplay)
+				desc := common.Tf("录像空间大小不足，当前大小: %s", sizeDisplay)
 				t.SetAbnormalEvent(desc, common.Critical)
 			}
 		}
 	} else {
-		t.result["ReplayUnused"] = common.Empty
+		t.result.ReplayUnused = common.Empty
 	}
 }
 
 func (t *ServiceTask) GetComponentLogSize() {
 	var components []Component
-	volumeDir := t.GetConfig("VOLUME_DIR", "/")
 	componentNames := []string{
 		"Nginx", "Core", "KoKo", "Lion", "Chen", "Kael", "Magnus",
 		"Panda", "Razor", "Video", "XRDP", "Facelive", "Nec",
 	}
 	for _, name := range componentNames {
-		var logPath string
 		var needRecord bool
-		version := t.GetConfig("CURRENT_VERSION", "v3")
-		subPath := strings.ToLower(name)
-		if subPath == "core" && strings.HasPrefix(version, "v2") {
-			logPath = filepath.Join(volumeDir, subPath, "logs")
-		} else {
-			logPath = filepath.Join(volumeDir, subPath, "data", "logs")
-		}
+		logPath := t.componentLogPath(name)
 		logSize := common.Empty
 		cmd := fmt.Sprintf(ComputeSpaceCommand, logPath)
 		command := Command{content: cmd, timeout: 5, withFailPipe: true}
@@ -95,12 +116,12 @@ func (t *ServiceTask) GetComponentLogSize() {
 		}
 		if needRecord {
 			components = append(components, Component{
-				ServiceName:    fmt.Sprintf("%s 日志大小", name),
+				ServiceName:    common.Tf("%s 日志大小", name),
 				ServiceLogSize: logSize,
 			})
 		}
 	}
-	t.result["ComponentLogSize"] = components
+	t.result.ComponentLogSize = components
 }
 
 func (t *ServiceTask) GetJMSServiceStatus() {
@@ -128,7 +149,11 @@ func (t *ServiceTask) GetJMSServiceStatus() {
 			})
 		}
 	}
-	t.result["ComponentInfo"] = components
+	t.result.ComponentInfo = components
+}
+
+func (t *ServiceTask) GetResult() (interface{}, []AbnormalMsg) {
+	return t.result, t.abnormalResult
 }
 
 func (t *ServiceTask) GetName() string {
@@ -138,6 +163,9 @@ func (t *ServiceTask) GetName() string {
 func (t *ServiceTask) Run() error {
 	t.GetReplayPathInfo()
 	t.GetComponentLogSize()
+	t.GetComponentLogErrors()
 	t.GetJMSServiceStatus()
+	t.GetComponentHealth()
+	t.GetContainerDetail()
 	return nil
 }
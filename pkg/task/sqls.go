@@ -12,9 +12,9 @@ import (
 )
 
 type TableInfo struct {
-	TableName   string
-	TableRecord string
-	TableSize   string
+	TableName   string `json:"table_name"`
+	TableRecord string `json:"table_record"`
+	TableSize   string `json:"table_size"`
 }
 
 type PieItem struct {
@@ -23,15 +23,35 @@ type PieItem struct {
 }
 
 type ChartCoordinate struct {
-	X     string
-	Y     string
-	XList []string
-	YList []string
+	X     string   `json:"-"`
+	Y     string   `json:"-"`
+	XList []string `json:"x_list"`
+	YList []string `json:"y_list"`
+}
+
+// UnmarshalJSON 从 JSON 报告中还原时同时补全图表所需的 X、Y 字符串
+func (c *ChartCoordinate) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		XList []string `json:"x_list"`
+		YList []string `json:"y_list"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.XList, c.YList = raw.XList, raw.YList
+	c.X, c.Y = "[]", "[]"
+	if data, err := json.Marshal(c.XList); err == nil && c.XList != nil {
+		c.X = string(data)
+	}
+	if data, err := json.Marshal(c.YList); err == nil && c.YList != nil {
+		c.Y = string(data)
+	}
+	return nil
 }
 
 type RDSInfo struct {
-	Name  string
-	Value any
+	Name  string `json:"name"`
+	Value any    `json:"value"`
 }
 
 type RDSClient interface {
@@ -43,6 +63,7 @@ type RDSClient interface {
 	GetRawRdsInfo() map[string]string
 	GetTableInfo() ([]TableInfo, error)
 	GetRDSInfo() ([]RDSInfo, error)
+	GetDBSize() (int64, error)
 	GetMaxLoginCount() string
 	GetMaxLoginAssetCount() string
 	GetMaxLoginUsersInLast3Months() string
@@ -251,6 +272,14 @@ func (c *MySQLClient) GetTableInfo() ([]TableInfo, error) {
 	return c.getTableInfo(query, true)
 }
 
+func (c *MySQLClient) GetDBSize() (int64, error) {
+	var size int64
+	query := "SELECT COALESCE(SUM(data_length + index_length), 0) " +
+		"FROM information_schema.tables WHERE table_schema = ?"
+	err := c.QueryRow(query, c.DBName).Scan(&size)
+	return size, err
+}
+
 func (c *MySQLClient) GetRDSInfo() ([]RDSInfo, error) {
 	var rdsInfos []RDSInfo
 	err := c.GetVariables("SHOW GLOBAL VARIABLES")
@@ -482,6 +511,12 @@ func (c *PostgreSQLClient) GetTableInfo() ([]TableInfo, error) {
 	return c.getTableInfo(query, false)
 }
 
+func (c *PostgreSQLClient) GetDBSize() (int64, error) {
+	var size int64
+	err := c.QueryRow("SELECT pg_database_size(current_database())").Scan(&size)
+	return size, err
+}
+
 func (c *PostgreSQLClient) GetRDSInfo() ([]RDSInfo, error) {
 	var rdsInfos []RDSInfo
 	err := c.GetVariables("SELECT name, setting FROM pg_settings")
@@ -0,0 +1,566 @@
+package task
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"inspect/pkg/common"
+)
+
+const (
+	StorageKindReplay  = "replay"
+	StorageKindCommand = "command"
+
+	storageTimeout = 10 * time.Second
+	// JumpServer 加密字段的前 72 个字符为 base64 编码的 header、nonce、tag
+	encryptedMetaHeaderLen = 72
+)
+
+// 支持 S3 协议的对象存储，oss、obs、cos 只支持虚拟主机方式访问存储桶
+var (
+	s3CompatibleTypes = map[string]bool{"s3": true, "ceph": true, "oss": true, "obs": true, "cos": true}
+	virtualHostTypes  = map[string]bool{"oss": true, "obs": true, "cos": true}
+
+	// 从各云厂商的访问地址中解析签名使用的区域
+	storageRegionRes = []*regexp.Regexp{
+		regexp.MustCompile(`^s3[.-]([a-z0-9-]+)\.amazonaws\.com`),
+		regexp.MustCompile(`^cos\.([a-z0-9-]+)\.myqcloud\.com`),
+		regexp.MustCompile(`^obs\.([a-z0-9-]+)\.myhuaweicloud\.com`),
+		regexp.MustCompile(`^(oss-[a-z0-9-]+?)(-internal)?\.aliyuncs\.com`),
+	}
+)
+
+// StorageInfo 录像或命令存储的配置及连通性检查结果，Passed 为空表示无需检查或无法检查
+type StorageInfo struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	IsDefault bool   `json:"is_default"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Passed    *bool  `json:"passed,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func (s StorageInfo) KindDisplay() string {
+	if s.Kind == StorageKindCommand {
+		return common.T("命令存储")
+	}
+	return common.T("录像存储")
+}
+
+// Failed 已检查且未通过
+func (s StorageInfo) Failed() bool {
+	return s.Passed != nil && !*s.Passed
+}
+
+func (s StorageInfo) StatusDisplay() string {
+	switch {
+	case s.Passed == nil:
+		return s.Message
+	case *s.Passed:
+		return common.T("正常")
+	default:
+		return common.Tf("异常: %s", s.Message)
+	}
+}
+
+// StorageTask 读取数据库中配置的录像及命令存储，由巡检工具直接检查连通性及凭据
+type StorageTask struct {
+	Task
+
+	client RDSClient
+	result []StorageInfo
+}
+
+func (t *StorageTask) Init(opts *Options) error {
+	t.Options = opts
+	if !opts.EnableStorage {
+		return nil
+	}
+	client, err := opts.GetRDSClient()
+	if err != nil {
+		return err
+	}
+	t.client = client
+	return nil
+}
+
+func processMetaKey(secretKey string) []byte {
+	key := []byte(secretKey)
+	if len(key) >= 32 {
+		return key[:32]
+	}
+	// 与 PKCS7 填充一致
+	padding := 32 - len(key)
+	for i := 0; i < padding; i++ {
+		key = append(key, byte(padding))
+	}
+	return key
+}
+
+// decryptGCM 对应 JumpServer 的 AESCryptoGCM，header 作为附加数据
+func decryptGCM(key []byte, text string) ([]byte, error) {
+	if len(text) <= encryptedMetaHeaderLen {
+		return nil, errors.New(common.T("密文长度不正确"))
+	}
+	var parts [][]byte
+	for _, part := range []string{text[:24], text[24:48], text[48:72], text[72:]} {
+		data, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, data)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(parts[1]))
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, parts[1], append(parts[3], parts[2]...), parts[0])
+}
+
+// decryptECB 对应旧版本的 AESCrypto，密钥及明文均以 \0 补齐到 16 的倍数
+func decryptECB(secretKey, text string) ([]byte, error) {
+	key := []byte(secretKey)
+	if len(key) > 32 {
+		key = key[:32]
+	}
+	for len(key)%aes.BlockSize != 0 {
+		key = append(key, 0)
+	}
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New(common.T("密文长度不正确"))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	for i := 0; i < len(data); i += aes.BlockSize {
+		block.Decrypt(plain[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+	}
+	return []byte(strings.TrimRight(string(plain), "\x00")), nil
+}
+
+// decryptStorageMeta meta 字段使用 SECRET_KEY 加密保存，依次尝试明文、GCM、ECB 三种格式
+func decryptStorageMeta(secretKey, text string) (map[string]interface{}, error) {
+	meta := make(map[string]interface{})
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return meta, nil
+	}
+	if err := json.Unmarshal([]byte(text), &meta); err == nil {
+		return meta, nil
+	}
+	if plain, err := decryptGCM(processMetaKey(secretKey), text); err == nil {
+		return meta, json.Unmarshal(plain, &meta)
+	}
+	if plain, err := decryptECB(secretKey, text); err == nil {
+		if err = json.Unmarshal(plain, &meta); err == nil {
+			return meta, nil
+		}
+	}
+	return nil, errors.New(common.T("无法使用 SECRET_KEY 解密存储配置"))
+}
+
+// metaValue 不同版本的配置项可能带有类型前缀，如 BUCKET、S3_BUCKET
+func metaValue(meta map[string]interface{}, storageType, key string) string {
+	for _, name := range []string{key, strings.ToUpper(storageType) + "_" + key} {
+		switch v := meta[name].(type) {
+		case string:
+			return v
+		case nil:
+			continue
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func metaList(meta map[string]interface{}, storageType, key string) []string {
+	for _, name := range []string{key, strings.ToUpper(storageType) + "_" + key} {
+		switch v := meta[name].(type) {
+		case []interface{}:
+			var result []string
+			for _, item := range v {
+				result = append(result, fmt.Sprint(item))
+			}
+			return result
+		case string:
+			return strings.Split(v, ",")
+		}
+	}
+	return nil
+}
+
+// registerMetaSecrets 避免存储的密钥出现在调试日志中
+func registerMetaSecrets(meta map[string]interface{}) {
+	for key, value := range meta {
+		if text, ok := value.(string); ok && common.IsSecretKey(key) {
+			common.RegisterSecret(text)
+		}
+	}
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signS3V4 按 AWS Signature V4 对请求签名，签名的请求头为 host 及请求中已有的全部请求头
+func signS3V4(req *http.Request, accessKey, secretKey, region string, now time.Time) {
+	payloadHash := sha256Hex("")
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.Query().Encode(), canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func storageRegion(meta map[string]interface{}, storageType, host string) string {
+	if region := metaValue(meta, storageType, "REGION"); region != "" {
+		return region
+	}
+	for _, re := range storageRegionRes {
+		if match := re.FindStringSubmatch(host); match != nil {
+			return match[1]
+		}
+	}
+	return "us-east-1"
+}
+
+func storageEndpoint(endpoint string) (*url.URL, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return url.Parse(endpoint)
+}
+
+func storageHTTPClient(insecure bool) *http.Client {
+	return &http.Client{
+		Timeout: storageTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkS3 对存储桶执行 HEAD 请求，可同时校验地址、存储桶及 AccessKey
+func checkS3(info *StorageInfo, meta map[string]interface{}) error {
+	info.Bucket = metaValue(meta, info.Type, "BUCKET")
+	endpoint := metaValue(meta, info.Type, "ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://s3.amazonaws.com"
+		if region := metaValue(meta, info.Type, "REGION"); region != "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		}
+	}
+	u, err := storageEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+	info.Endpoint = u.Redacted()
+	region := storageRegion(meta, info.Type, u.Hostname())
+	if virtualHostTypes[info.Type] {
+		u.Host = info.Bucket + "." + u.Host
+		u.Path = "/"
+	} else {
+		u.Path = "/" + info.Bucket
+	}
+	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	signS3V4(req, metaValue(meta, info.Type, "ACCESS_KEY"), metaValue(meta, info.Type, "SECRET_KEY"), region, time.Now())
+	resp, err := storageHTTPClient(false).Do(req)
+	if err != nil {
+		return fmt.Errorf(common.T("无法连接: %s"), err)
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusForbidden:
+		return errors.New(common.T("认证失败或无权访问存储桶(403)"))
+	case resp.StatusCode == http.StatusNotFound:
+		return errors.New(common.T("存储桶不存在(404)"))
+	case resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf(common.T("区域或访问地址配置错误(%d)"), resp.StatusCode)
+	default:
+		return fmt.Errorf(common.T("状态码 %d"), resp.StatusCode)
+	}
+}
+
+// checkAzure Azure 使用 SharedKey 签名，这里只检查网络是否可达
+func checkAzure(info *StorageInfo, meta map[string]interface{}) error {
+	suffix := metaValue(meta, info.Type, "ENDPOINT_SUFFIX")
+	if suffix == "" {
+		suffix = "core.windows.net"
+	}
+	info.Bucket = metaValue(meta, info.Type, "CONTAINER_NAME")
+	info.Endpoint = fmt.Sprintf("%s.blob.%s", metaValue(meta, info.Type, "ACCOUNT_NAME"), suffix)
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: storageTimeout}, "tcp", info.Endpoint+":443", nil)
+	if err != nil {
+		return fmt.Errorf(common.T("无法连接: %s"), err)
+	}
+	_ = conn.Close()
+	info.Message = common.T("网络可达，未校验凭据")
+	return nil
+}
+
+func checkSFTP(info *StorageInfo, meta map[string]interface{}) error {
+	host := metaValue(meta, info.Type, "SFTP_HOST")
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+	info.Endpoint = host
+	info.Bucket = metaValue(meta, info.Type, "STORAGE_PATH")
+	var auth ssh.AuthMethod
+	if metaValue(meta, info.Type, "SFTP_SECRET_TYPE") == "ssh_key" {
+		key := []byte(metaValue(meta, info.Type, "SFTP_PRIVATE_KEY"))
+		var signer ssh.Signer
+		var err error
+		if passphrase := metaValue(meta, info.Type, "SFTP_PASSPHRASE"); passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return fmt.Errorf(common.T("密钥文件解析失败: %w"), err)
+		}
+		auth = ssh.PublicKeys(signer)
+	} else {
+		auth = ssh.Password(metaValue(meta, info.Type, "SFTP_PASSWORD"))
+	}
+	client, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            metaValue(meta, info.Type, "SFTP_USERNAME"),
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         storageTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf(common.T("无法连接: %s"), err)
+	}
+	_ = client.Close()
+	return nil
+}
+
+// checkES 逐个访问 ES 节点，地址中可带有用户名密码
+func checkES(info *StorageInfo, meta map[string]interface{}) error {
+	info.Bucket = metaValue(meta, info.Type, "INDEX")
+	insecure := strings.EqualFold(metaValue(meta, info.Type, "IGNORE_VERIFY_CERTS"), "true")
+	client := storageHTTPClient(insecure)
+	var endpoints, failures []string
+	for _, host := range metaList(meta, info.Type, "HOSTS") {
+		u, err := url.Parse(strings.TrimSpace(host))
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if password, ok := u.User.Password(); ok {
+			common.RegisterSecret(password)
+		}
+		endpoints = append(endpoints, u.Redacted())
+		resp, err := client.Get(u.String())
+		if err != nil {
+			failures = append(failures, common.Tf("%s 无法连接: %s", u.Redacted(), err))
+			continue
+		}
+		_ = resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			failures = append(failures, common.Tf("%s 认证失败(%d)", u.Redacted(), resp.StatusCode))
+		case resp.StatusCode >= 300:
+			failures = append(failures, common.Tf("%s 状态码 %d", u.Redacted(), resp.StatusCode))
+		}
+	}
+	info.Endpoint = strings.Join(endpoints, "\n")
+	if len(endpoints) == 0 && len(failures) == 0 {
+		return errors.New(common.T("未配置 ES 地址"))
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (t *StorageTask) check(info *StorageInfo, metaText string) {
+	var checker func(*StorageInfo, map[string]interface{}) error
+	switch {
+	case info.Type == "server" && info.Kind == StorageKindReplay:
+		info.Message = common.T("本地存储")
+		return
+	case info.Type == "server":
+		info.Message = common.T("数据库存储")
+		return
+	case info.Type == "null":
+		info.Message = common.T("不存储")
+		return
+	case s3CompatibleTypes[info.Type]:
+		checker = checkS3
+	case info.Type == "azure":
+		checker = checkAzure
+	case info.Type == "sftp":
+		checker = checkSFTP
+	case info.Type == "es" || info.Type == "elasticsearch":
+		checker = checkES
+	default:
+		info.Message = common.T("暂不支持检查该类型的存储")
+		return
+	}
+	meta, err := decryptStorageMeta(t.GetConfig("SECRET_KEY", ""), metaText)
+	if err != nil {
+		// 多为巡检使用的配置文件与实际运行的不一致，不代表存储本身异常，按未检查处理
+		info.Message = err.Error()
+		t.SetAbnormalEvent(common.Tf("%s %s(%s) 检查失败: %s", info.KindDisplay(), info.Name, info.Type, err), common.Normal)
+		return
+	}
+	registerMetaSecrets(meta)
+	passed := false
+	info.Passed = &passed
+	if err = checker(info, meta); err != nil {
+		info.Message = err.Error()
+		level := common.Alert
+		if info.IsDefault {
+			level = common.Critical
+		}
+		t.SetAbnormalEvent(common.Tf("%s %s(%s) 检查失败: %s", info.KindDisplay(), info.Name, info.Type, err), level)
+		return
+	}
+	passed = true
+}
+
+// queryStorages 旧版本的存储表没有 is_default 字段
+func (t *StorageTask) queryStorages(kind, table string) error {
+	rows, err := t.client.Query(fmt.Sprintf("SELECT name, type, meta, is_default FROM %s ORDER BY name", table))
+	withDefault := err == nil
+	if !withDefault {
+		rows, err = t.client.Query(fmt.Sprintf("SELECT name, type, meta FROM %s ORDER BY name", table))
+	}
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	for rows.Next() {
+		info := StorageInfo{Kind: kind}
+		var meta sql.NullString
+		dest := []interface{}{&info.Name, &info.Type, &meta}
+		if withDefault {
+			dest = append(dest, &info.IsDefault)
+		}
+		if err = rows.Scan(dest...); err != nil {
+			continue
+		}
+		t.check(&info, meta.String)
+		t.result = append(t.result, info)
+	}
+	return rows.Err()
+}
+
+func (t *StorageTask) GetResult() (interface{}, []AbnormalMsg) {
+	return t.result, t.abnormalResult
+}
+
+func (t *StorageTask) GetName() string {
+	return "录像及命令存储检查"
+}
+
+func (t *StorageTask) Run() error {
+	if !t.Options.EnableStorage {
+		return nil
+	}
+	defer func(client RDSClient) {
+		_ = client.Close()
+	}(t.client)
+	var errs []error
+	if err := t.queryStorages(StorageKindReplay, "terminal_replaystorage"); err != nil {
+		errs = append(errs, err)
+	}
+	if err := t.queryStorages(StorageKindCommand, "terminal_commandstorage"); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// storageMetrics 存储连通性检查结果，无需检查或无法解密配置的存储不输出
+func (r *ResultSummary) storageMetrics(c *metricCollector) {
+	for _, s := range r.StorageResult {
+		if s.Passed == nil {
+			continue
+		}
+		c.metrics = append(c.metrics, Metric{
+			Name: "storage_up", Labels: map[string]string{"kind": s.Kind, "name": s.Name, "type": s.Type},
+			Value: boolValue(*s.Passed), Unit: UnitBool, Display: s.StatusDisplay(),
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package task
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"inspect/pkg/common"
+)
+
+const (
+	tlsDialTimeout = 5 * time.Second
+	// 证书剩余有效天数阈值
+	tlsCriticalDays = 7
+	tlsAlertDays    = 30
+
+	TLSProtocolHTTPS      = "https"
+	TLSProtocolMySQL      = "mysql"
+	TLSProtocolPostgreSQL = "postgresql"
+	TLSProtocolRDP        = "rdp"
+)
+
+// 各协议对应的端口配置项，未配置的端口不检查
+var tlsPortConfigs = []struct {
+	key      string
+	protocol string
+}{
+	{"HTTPS_PORT", TLSProtocolHTTPS},
+	{"MAGNUS_MYSQL_PORT", TLSProtocolMySQL},
+	{"MAGNUS_MARIADB_PORT", TLSProtocolMySQL},
+	{"MAGNUS_POSTGRESQL_PORT", TLSProtocolPostgreSQL},
+	{"XRDP_PORT", TLSProtocolRDP},
+	{"RDP_PORT", TLSProtocolRDP},
+}
+
+var tlsVersions = []struct {
+	version uint16
+	name    string
+	// 已不安全的协议版本
+	insecure bool
+}{
+	{tls.VersionTLS10, "TLS 1.0", true},
+	{tls.VersionTLS11, "TLS 1.1", true},
+	{tls.VersionTLS12, "TLS 1.2", false},
+	{tls.VersionTLS13, "TLS 1.3", false},
+}
+
+type TLSCertInfo struct {
+	// 机器名称或域名
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Protocol string `json:"protocol"`
+
+	Subject    string     `json:"subject,omitempty"`
+	SANs       []string   `json:"sans,omitempty"`
+	Issuer     string     `json:"issuer,omitempty"`
+	NotAfter   *time.Time `json:"not_after,omitempty"`
+	DaysLeft   int        `json:"days_left"`
+	ChainValid bool       `json:"chain_valid"`
+	ChainError string     `json:"chain_error,omitempty"`
+	Versions   []string   `json:"versions,omitempty"`
+	// 连接或握手失败的原因
+	Error string `json:"error,omitempty"`
+}
+
+func (c TLSCertInfo) AddressDisplay() string {
+	return fmt.Sprintf("%s (%s)", c.Address, c.Protocol)
+}
+
+func (c TLSCertInfo) SubjectDisplay() string {
+	if c.Error != "" {
+		return common.Tf("检查失败: %s", c.Error)
+	}
+	return c.Subject
+}
+
+func (c TLSCertInfo) SANDisplay() string {
+	if len(c.SANs) == 0 {
+		return common.EmptyFlag
+	}
+	return strings.Join(c.SANs, ", ")
+}
+
+func (c TLSCertInfo) ChainDisplay() string {
+	if c.Error != "" {
+		return common.EmptyFlag
+	}
+	if c.ChainValid {
+		return common.T("有效")
+	}
+	return common.Tf("无效: %s", c.ChainError)
+}
+
+func (c TLSCertInfo) VersionDisplay() string {
+	if len(c.Versions) == 0 {
+		return common.EmptyFlag
+	}
+	return strings.Join(c.Versions, ", ")
+}
+
+func (c TLSCertInfo) ExpiryDisplay() string {
+	if c.NotAfter == nil {
+		return common.EmptyFlag
+	}
+	return common.Tf("%d 天(%s)", c.DaysLeft, c.NotAfter.Local().Format("2006-01-02"))
+}
+
+type tlsEndpoint struct {
+	name     string
+	host     string
+	port     string
+	protocol string
+	// SNI 及证书校验使用的域名，为空时不校验域名
+	serverName string
+}
+
+func (e tlsEndpoint) address() string {
+	return net.JoinHostPort(e.host, e.port)
+}
+
+// TLSTask 由巡检工具直接连接各节点的 HTTPS 及组件端口，检查证书及协议版本
+type TLSTask struct {
+	Task
+
+	result []TLSCertInfo
+}
+
+func (t *TLSTask) Init(opts *Options) error {
+	t.Options = opts
+	return nil
+}
+
+// domains 解析 DOMAINS 配置，如 demo.jumpserver.org:443,jms.example.com
+func (t *TLSTask) domains() []tlsEndpoint {
+	var endpoints []tlsEndpoint
+	for _, domain := range strings.Split(t.GetConfig("DOMAINS", ""), ",") {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(domain)
+		if err != nil {
+			host, port = domain, "443"
+		}
+		endpoints = append(endpoints, tlsEndpoint{
+			name: domain, host: host, port: port, protocol: TLSProtocolHTTPS, serverName: host,
+		})
+	}
+	return endpoints
+}
+
+func (t *TLSTask) endpoints() []tlsEndpoint {
+	domains := t.domains()
+	// 通过 IP 访问节点时使用第一个域名作为 SNI，以获取对外使用的证书
+	var serverName string
+	if len(domains) > 0 && net.ParseIP(domains[0].host) == nil {
+		serverName = domains[0].host
+	}
+	var endpoints []tlsEndpoint
+	exist := make(map[string]bool)
+	for _, m := range t.Options.MachineSet {
+		if m.Type != common.JumpServer {
+			continue
+		}
+		for _, item := range tlsPortConfigs {
+			port := strings.TrimSpace(t.GetConfig(item.key, ""))
+			if port == "" {
+				continue
+			}
+			endpoint := tlsEndpoint{name: m.Name, host: m.Host, port: port, protocol: item.protocol}
+			if item.protocol == TLSProtocolHTTPS {
+				endpoint.serverName = serverName
+			}
+			if !exist[endpoint.address()] {
+				exist[endpoint.address()] = true
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	for _, endpoint := range domains {
+		if !exist[endpoint.address()] {
+			exist[endpoint.address()] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// mysqlStartTLS 读取握手包并发送 SSLRequest
+func mysqlStartTLS(conn net.Conn) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+	if len(payload) == 0 || payload[0] == 0xff {
+		return errors.New(common.T("服务端拒绝连接"))
+	}
+	// 协议版本(1)、服务端版本(以 0 结尾)、连接 ID(4)、随机数(8)、填充(1)、能力标志低位(2)
+	end := bytes.IndexByte(payload[1:], 0)
+	pos := 1 + end + 1 + 4 + 8 + 1
+	if end == -1 || len(payload) < pos+2 {
+		return errors.New(common.T("无法解析握手包"))
+	}
+	const clientSSL, clientProtocol41, clientSecureConnection = 0x0800, 0x0200, 0x8000
+	if binary.LittleEndian.Uint16(payload[pos:])&clientSSL == 0 {
+		return errors.New(common.T("服务端未开启 TLS"))
+	}
+	request := make([]byte, 4+32)
+	request[0], request[3] = 32, header[3]+1
+	binary.LittleEndian.PutUint32(request[4:], clientSSL|clientProtocol41|clientSecureConnection)
+	binary.LittleEndian.PutUint32(request[8:], 1<<24)
+	request[12] = 33
+	_, err := conn.Write(request)
+	return err
+}
+
+// postgresStartTLS 发送 SSLRequest，服务端返回 S 表示支持 TLS
+func postgresStartTLS(conn net.Conn) error {
+	if _, err := conn.Write([]byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+		return err
+	}
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 'S' {
+		return errors.New(common.T("服务端未开启 TLS"))
+	}
+	return nil
+}
+
+// rdpStartTLS 发送 X.224 连接请求协商 TLS/CredSSP，两者均先建立 TLS 连接
+func rdpStartTLS(conn net.Conn) error {
+	request := []byte{
+		0x03, 0x00, 0x00, 0x13, // TPKT
+		0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, // X.224 Connection Request
+		0x01, 0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00, // RDP_NEG_REQ: PROTOCOL_SSL | PROTOCOL_HYBRID
+	}
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	if length < 4 {
+		return errors.New(common.T("无法解析握手包"))
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return err
+	}
+	// X.224 连接确认(7) 后为 RDP_NEG_RSP，类型 2 为成功，3 为失败
+	if len(body) < 15 || body[7] != 0x02 || binary.LittleEndian.Uint32(body[11:]) == 0 {
+		return errors.New(common.T("服务端未开启 TLS"))
+	}
+	return nil
+}
+
+func dialTLS(endpoint tlsEndpoint, config *tls.Config) (*tls.Conn, error) {
+	conn, err := net.DialTimeout("tcp", endpoint.address(), tlsDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(tlsDialTimeout))
+	switch endpoint.protocol {
+	case TLSProtocolMySQL:
+		err = mysqlStartTLS(conn)
+	case TLSProtocolPostgreSQL:
+		err = postgresStartTLS(conn)
+	case TLSProtocolRDP:
+		err = rdpStartTLS(conn)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, config)
+	if err = tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// supportedVersions 逐个协议版本握手，包含已不安全的加密套件以便检测旧版本
+func supportedVersions(endpoint tlsEndpoint) []string {
+	var suites []uint16
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites = append(suites, suite.ID)
+	}
+	var versions []string
+	for _, v := range tlsVersions {
+		conn, err := dialTLS(endpoint, &tls.Config{
+			InsecureSkipVerify: true, ServerName: endpoint.serverName,
+			MinVersion: v.version, MaxVersion: v.version, CipherSuites: suites,
+		})
+		if err != nil {
+			continue
+		}
+		_ = conn.Close()
+		versions = append(versions, v.name)
+	}
+	return versions
+}
+
+func (t *TLSTask) setAbnormal(node, desc, level string) {
+	t.SetAbnormalEvent(desc, level)
+	t.abnormalResult[len(t.abnormalResult)-1].NodeName = node
+}
+
+func (t *TLSTask) inspect(endpoint tlsEndpoint) TLSCertInfo {
+	info := TLSCertInfo{Name: endpoint.name, Address: endpoint.address(), Protocol: endpoint.protocol}
+	conn, err := dialTLS(endpoint, &tls.Config{InsecureSkipVerify: true, ServerName: endpoint.serverName})
+	if err != nil {
+		info.Error = err.Error()
+		level := common.Slight
+		if endpoint.protocol == TLSProtocolHTTPS {
+			level = common.Normal
+		}
+		t.setAbnormal(endpoint.name, common.Tf("%s 的 TLS 连接失败: %s", info.AddressDisplay(), err), level)
+		return info
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	_ = conn.Close()
+	if len(certs) == 0 {
+		info.Error = common.T("服务端未返回证书")
+		return info
+	}
+	leaf := certs[0]
+	info.Subject, info.Issuer = leaf.Subject.String(), leaf.Issuer.String()
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	notAfter := leaf.NotAfter
+	info.NotAfter = &notAfter
+	info.DaysLeft = int(math.Floor(time.Until(notAfter).Hours() / 24))
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: endpoint.serverName, Intermediates: intermediates})
+	info.ChainValid = err == nil
+	if err != nil {
+		info.ChainError = err.Error()
+		t.setAbnormal(endpoint.name, common.Tf("%s 的证书链校验失败: %s", info.AddressDisplay(), err), common.Normal)
+	}
+	info.Versions = supportedVersions(endpoint)
+	for _, v := range tlsVersions {
+		for _, name := range info.Versions {
+			if v.insecure && name == v.name {
+				t.setAbnormal(endpoint.name, common.Tf("%s 启用了不安全的协议版本 %s", info.AddressDisplay(), v.name), common.Normal)
+			}
+		}
+	}
+
+	expiry := notAfter.Local().Format("2006-01-02")
+	switch {
+	case info.DaysLeft < 0:
+		t.setAbnormal(endpoint.name, common.Tf("%s 的证书已于 %s 过期", info.AddressDisplay(), expiry), common.Critical)
+	case info.DaysLeft <= tlsCriticalDays:
+		t.setAbnormal(endpoint.name, common.Tf("%s 的证书将于 %d 天后(%s)过期", info.AddressDisplay(), info.DaysLeft, expiry), common.Critical)
+	case info.DaysLeft <= tlsAlertDays:
+		t.setAbnormal(endpoint.name, common.Tf("%s 的证书将于 %d 天后(%s)过期", info.AddressDisplay(), info.DaysLeft, expiry), common.Alert)
+	}
+	return info
+}
+
+func (t *TLSTask) GetResult() (interface{}, []AbnormalMsg) {
+	return t.result, t.abnormalResult
+}
+
+func (t *TLSTask) GetName() string {
+	return "TLS 证书检查"
+}
+
+func (t *TLSTask) Run() error {
+	if !t.Options.EnableTLS {
+		return nil
+	}
+	for _, endpoint := range t.endpoints() {
+		t.result = append(t.result, t.inspect(endpoint))
+	}
+	return nil
+}
+
+// tlsMetrics 证书剩余有效天数
+func (r *ResultSummary) tlsMetrics(c *metricCollector) {
+	for _, info := range r.TLSResult {
+		if info.NotAfter == nil {
+			continue
+		}
+		c.metrics = append(c.metrics, Metric{
+			Name: "tls_cert_expiry_days", Node: info.Name,
+			Labels: map[string]string{"address": info.Address, "protocol": info.Protocol},
+			Value:  float64(info.DaysLeft), Unit: UnitCount, Display: strconv.Itoa(info.DaysLeft),
+		})
+	}
+}
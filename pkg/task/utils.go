@@ -6,19 +6,37 @@ import (
 	"time"
 )
 
-func DoTask(task AbstractTask, opts *Options) (map[string]interface{}, []AbnormalMsg) {
-	logger := common.GetLogger()
+// TaskRecord 单个任务的执行记录，供 JUnit 等报告区分任务是否执行出错
+type TaskRecord struct {
+	Name     string  `json:"name"`
+	NodeName string  `json:"node_name"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+func DoTask(task AbstractTask, opts *Options) (interface{}, []AbnormalMsg, TaskRecord) {
+	logger := opts.Logger
+	record := TaskRecord{Name: task.GetName()}
 	start := time.Now()
-	err := task.Init(opts)
-	if err != nil {
+	if err := task.Init(opts); err != nil {
 		logger.Error("初始化任务失败: %s", err)
+		record.Error = err.Error()
+		record.Duration = time.Now().Sub(start).Seconds()
+		result, abnormalResult := task.GetResult()
+		return result, abnormalResult, record
 	}
-	logger.StartTip("正在执行任务：%s", task.GetName())
-	err = task.Run()
-	duration := strconv.FormatFloat(time.Now().Sub(start).Seconds(), 'f', 2, 64)
-	logger.StopTip("[成功]:> 执行任务：%s（耗时：%s秒）", task.GetName(), duration)
+	logger.StartTip("正在执行任务：%s", common.T(task.GetName()))
+	err := task.Run()
+	record.Duration = time.Now().Sub(start).Seconds()
+	duration := strconv.FormatFloat(record.Duration, 'f', 2, 64)
+	logger.StopTip("[成功]:> 执行任务：%s（耗时：%s秒）", common.T(task.GetName()), duration)
 	if err != nil {
 		logger.Warning("执行任务出错: %s", err)
+		record.Error = err.Error()
+	}
+	result, abnormalResult := task.GetResult()
+	for i := range abnormalResult {
+		abnormalResult[i].Task = record.Name
 	}
-	return task.GetResult()
+	return result, abnormalResult, record
 }
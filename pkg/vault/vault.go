@@ -0,0 +1,265 @@
+// Package vault 加解密机器配置中的密码，密文格式为 vault:v1:base64(salt|nonce|ciphertext)，
+// 使用 scrypt 由主密码派生密钥，AES-256-GCM 加密
+// 派生密钥较慢，同一进程内加密使用同一个 salt，派生的密钥按 salt 缓存，加解密整个文件只需派生一次
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v3"
+
+	"inspect/pkg/common"
+)
+
+const (
+	Prefix = "vault:v1:"
+	// PasswordEnv 主密码环境变量
+	PasswordEnv = "JMS_INSPECT_VAULT_PASSWORD"
+
+	saltSize = 16
+	keySize  = 32
+	scryptN  = 1 << 15
+	scryptR  = 8
+	scryptP  = 1
+)
+
+// SecretFields 配置文件中需要加密的字段
+var SecretFields = []string{"password", "ssh_key_passphrase", "privilege_password"}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+var keyCache = struct {
+	sync.Mutex
+	// 主密码摘要 + salt 对应的 AEAD
+	aeads map[string]cipher.AEAD
+	// 主密码摘要对应的加密用 salt
+	salts map[string][]byte
+}{aeads: make(map[string]cipher.AEAD), salts: make(map[string][]byte)}
+
+func passphraseDigest(passphrase string) string {
+	digest := sha256.Sum256([]byte(passphrase))
+	return string(digest[:])
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	cacheKey := passphraseDigest(passphrase) + string(salt)
+	keyCache.Lock()
+	defer keyCache.Unlock()
+	if gcm, exist := keyCache.aeads[cacheKey]; exist {
+		return gcm, nil
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	keyCache.aeads[cacheKey] = gcm
+	return gcm, nil
+}
+
+// encryptSalt 同一主密码在进程内首次加密时生成随机 salt，之后复用
+func encryptSalt(passphrase string) ([]byte, error) {
+	digest := passphraseDigest(passphrase)
+	keyCache.Lock()
+	defer keyCache.Unlock()
+	if salt, exist := keyCache.salts[digest]; exist {
+		return salt, nil
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	keyCache.salts[digest] = salt
+	return salt, nil
+}
+
+// Encrypt 每次加密使用随机的 nonce，相同明文的密文也不相同
+func Encrypt(plaintext, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", errors.New(common.T("主密码不能为空"))
+	}
+	salt, err := encryptSalt(passphrase)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := append(append([]byte{}, salt...), nonce...)
+	data = gcm.Seal(data, nonce, []byte(plaintext), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt 非密文直接原样返回
+func Decrypt(value, passphrase string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil || len(data) < saltSize {
+		return "", errors.New(common.T("密文格式错误"))
+	}
+	gcm, err := newGCM(passphrase, data[:saltSize])
+	if err != nil {
+		return "", err
+	}
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New(common.T("密文格式错误"))
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New(common.T("解密失败，主密码错误或密文已损坏"))
+	}
+	return string(plaintext), nil
+}
+
+// PasswordFromEnvOrFile 优先读取环境变量，其次读取文件，都未指定时返回空字符串
+func PasswordFromEnvOrFile(passwordFile string) (string, error) {
+	if value := os.Getenv(PasswordEnv); value != "" {
+		return value, nil
+	}
+	if passwordFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return "", fmt.Errorf(common.T("读取主密码文件 %s 失败: %s"), passwordFile, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func isSecretField(key string) bool {
+	for _, field := range SecretFields {
+		if key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TransformFile 对 YAML 文件中所有密码字段执行 transform，保留注释及格式，返回修改的字段数
+func TransformFile(path string, transform func(value string) (string, bool, error)) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return 0, fmt.Errorf(common.T("解析 YAML 文件 %s 失败: %s"), path, err)
+	}
+	count, err := transformNode(&doc, transform)
+	if err != nil || count == 0 {
+		return count, err
+	}
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return 0, err
+	}
+	return count, writeFileAtomic(path, []byte(b.String()), info.Mode().Perm())
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，写入中断时原文件保持不变
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	defer func(tempPath string) {
+		_ = os.Remove(tempPath)
+	}(tempPath)
+	if err = temp.Chmod(perm); err == nil {
+		if _, err = temp.Write(data); err == nil {
+			err = temp.Sync()
+		}
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func transformNode(node *yaml.Node, transform func(value string) (string, bool, error)) (int, error) {
+	var count int
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind != yaml.ScalarNode || !isSecretField(key.Value) || value.Value == "" {
+				continue
+			}
+			newValue, changed, err := transform(value.Value)
+			if err != nil {
+				return count, fmt.Errorf(common.T("第 %d 行 %s 字段处理失败: %s"), value.Line, key.Value, err)
+			}
+			if changed {
+				value.Value, value.Tag, value.Style = newValue, "!!str", yaml.DoubleQuotedStyle
+				count += 1
+			}
+		}
+	}
+	for _, child := range node.Content {
+		n, err := transformNode(child, transform)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// EncryptFile 加密文件中尚未加密的密码字段
+func EncryptFile(path, passphrase string) (int, error) {
+	return TransformFile(path, func(value string) (string, bool, error) {
+		if IsEncrypted(value) {
+			return value, false, nil
+		}
+		encrypted, err := Encrypt(value, passphrase)
+		return encrypted, err == nil, err
+	})
+}
+
+// DecryptFile 将文件中已加密的密码字段还原为明文
+func DecryptFile(path, passphrase string) (int, error) {
+	return TransformFile(path, func(value string) (string, bool, error) {
+		if !IsEncrypted(value) {
+			return value, false, nil
+		}
+		plaintext, err := Decrypt(value, passphrase)
+		return plaintext, err == nil, err
+	})
+}
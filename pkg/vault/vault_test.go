@@ -0,0 +1,154 @@
+package vault
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, plaintext := range []string{"Jms@Passw0rd!", "123456", "", "中文密码 with spaces"} {
+		encrypted, err := Encrypt(plaintext, testPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(encrypted) || (plaintext != "" && strings.Contains(encrypted, plaintext)) {
+			t.Fatalf("Encrypt(%q) = %q", plaintext, encrypted)
+		}
+		again, _ := Encrypt(plaintext, testPassphrase)
+		if again == encrypted {
+			t.Errorf("相同明文两次加密的密文相同: %s", encrypted)
+		}
+		decrypted, err := Decrypt(encrypted, testPassphrase)
+		if err != nil || decrypted != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", plaintext, decrypted, err)
+		}
+	}
+	if _, err := Encrypt("x", ""); err == nil {
+		t.Error("主密码为空时应返回错误")
+	}
+	if value, err := Decrypt("plain", testPassphrase); err != nil || value != "plain" {
+		t.Errorf("Decrypt(明文) = %q, %v", value, err)
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	encrypted, err := Encrypt("Jms@Passw0rd!", testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, Prefix))
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-1] ^= 0xff
+	cases := []struct {
+		name       string
+		value      string
+		passphrase string
+	}{
+		{"主密码错误", encrypted, "wrong passphrase"},
+		{"密文被篡改", Prefix + base64.StdEncoding.EncodeToString(tampered), testPassphrase},
+		{"密文被截断", Prefix + base64.StdEncoding.EncodeToString(data[:len(data)-4]), testPassphrase},
+		{"只有 salt", Prefix + base64.StdEncoding.EncodeToString(data[:saltSize]), testPassphrase},
+		{"长度不足 salt", Prefix + base64.StdEncoding.EncodeToString(data[:4]), testPassphrase},
+		{"非 base64", Prefix + "!!!", testPassphrase},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if value, err := Decrypt(c.value, c.passphrase); err == nil {
+				t.Errorf("Decrypt = %q, want error", value)
+			}
+		})
+	}
+}
+
+const testInventory = `# 机器配置
+machines:
+  - name: jms1 # 堡垒机节点
+    host: 10.0.0.1
+    username: root
+    password: Jms@Passw0rd!
+    privilege_password: 123456
+  - name: db
+    host: 10.0.0.2
+    ssh_key_passphrase: 'key pass'
+    password: ""
+`
+
+func writeInventory(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "machine.yml")
+	if err := os.WriteFile(path, []byte(testInventory), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptFile(t *testing.T) {
+	path := writeInventory(t)
+	count, err := EncryptFile(path, testPassphrase)
+	if err != nil || count != 3 {
+		t.Fatalf("EncryptFile = %d, %v, want 3", count, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, secret := range []string{"Jms@Passw0rd!", "123456", "key pass"} {
+		if strings.Contains(content, secret) {
+			t.Errorf("加密后的文件中包含明文 %s", secret)
+		}
+	}
+	for _, comment := range []string{"# 机器配置", "# 堡垒机节点"} {
+		if !strings.Contains(content, comment) {
+			t.Errorf("加密后的文件丢失注释 %s", comment)
+		}
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("文件权限 = %v, want 0600", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("目录中残留临时文件: %v", entries)
+	}
+
+	// 已加密的字段不再重复加密，文件内容不变
+	count, err = EncryptFile(path, testPassphrase)
+	if err != nil || count != 0 {
+		t.Fatalf("再次 EncryptFile = %d, %v, want 0", count, err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != content {
+		t.Error("再次加密修改了文件内容")
+	}
+
+	if _, err = DecryptFile(path, "wrong passphrase"); err == nil {
+		t.Error("主密码错误时 DecryptFile 应返回错误")
+	}
+	if again, _ := os.ReadFile(path); string(again) != content {
+		t.Error("解密失败时修改了文件内容")
+	}
+
+	count, err = DecryptFile(path, testPassphrase)
+	if err != nil || count != 3 {
+		t.Fatalf("DecryptFile = %d, %v, want 3", count, err)
+	}
+	var inventory struct {
+		Machines []struct {
+			Password          string `yaml:"password"`
+			PrivilegePassword string `yaml:"privilege_password"`
+			SSHKeyPassphrase  string `yaml:"ssh_key_passphrase"`
+		} `yaml:"machines"`
+	}
+	data, _ = os.ReadFile(path)
+	if err = yaml.Unmarshal(data, &inventory); err != nil {
+		t.Fatal(err)
+	}
+	got := inventory.Machines
+	if got[0].Password != "Jms@Passw0rd!" || got[0].PrivilegePassword != "123456" || got[1].SSHKeyPassphrase != "key pass" {
+		t.Errorf("解密后的配置 = %+v", got)
+	}
+}